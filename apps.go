@@ -14,6 +14,7 @@ type appManager interface {
 	DestroyApp(appName string) error
 	CheckAppExists(appName string) (bool, error)
 	ListApps() ([]string, error)
+	ListAppSummaries(options *AppSummaryOptions) ([]*AppSummary, error)
 	LockApp(appName string) error
 	IsLocked(appName string) (bool, error)
 	RenameApp(currentAppName string, newAppName string, options *AppManagementOptions) error
@@ -0,0 +1,145 @@
+package dokku
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+type AppSummary struct {
+	Name         string         `json:"name"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Locked       bool           `json:"locked"`
+	DeploySource string         `json:"deploy_source"`
+	Deployed     bool           `json:"deployed"`
+	Running      bool           `json:"running"`
+	Processes    map[string]int `json:"processes"`
+	Domains      []string       `json:"domains"`
+	SHA          string         `json:"sha"`
+}
+
+func (s *AppSummary) ProcessCount() int {
+	total := 0
+	for _, n := range s.Processes {
+		total += n
+	}
+	return total
+}
+
+type AppSummarySortKey string
+
+const (
+	AppSummarySortByName         = AppSummarySortKey("name")
+	AppSummarySortByCreatedAt    = AppSummarySortKey("created_at")
+	AppSummarySortByDeploySource = AppSummarySortKey("deploy_source")
+	AppSummarySortByProcessCount = AppSummarySortKey("process_count")
+)
+
+type AppSummaryOptions struct {
+	// optional, only apps for which Filter returns true are listed
+	Filter func(summary *AppSummary) bool
+	// optional, defaults to sorting by name
+	SortBy     AppSummarySortKey
+	Descending bool
+}
+
+// countProcesses counts containers per process type.
+func countProcesses(statuses []ProcessStatus) map[string]int {
+	counts := map[string]int{}
+	for _, status := range statuses {
+		counts[status.Process]++
+	}
+	return counts
+}
+
+// ListAppSummaries joins the bulk apps, ps, domains and git reports into a
+// summary per app, using a single call for each report.
+func (c *BaseClient) ListAppSummaries(options *AppSummaryOptions) ([]*AppSummary, error) {
+	appReports, err := c.GetAllAppReport()
+	if errors.Is(err, NoDeployedAppsError) {
+		return []*AppSummary{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	psReports, err := c.GetAllProcessReport()
+	if err != nil {
+		return nil, err
+	}
+
+	domainsReports, err := c.GetDomainsReport()
+	if err != nil {
+		return nil, err
+	}
+	gitReports, err := c.GitGetReport()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []*AppSummary{}
+	for appName, appReport := range appReports {
+		summary := &AppSummary{
+			Name:         appName,
+			Locked:       appReport.IsLocked,
+			DeploySource: appReport.DeploySource,
+			Processes:    map[string]int{},
+		}
+		if appReport.CreatedAtTimestamp > 0 {
+			summary.CreatedAt = time.Unix(appReport.CreatedAtTimestamp, 0)
+		}
+		if ps, ok := psReports[appName]; ok {
+			summary.Deployed = ps.Deployed
+			summary.Running = ps.Running
+			summary.Processes = countProcesses(ps.Statuses)
+		}
+		if domains, ok := domainsReports[appName]; ok {
+			summary.Domains = domains.AppDomains
+		}
+		if git, ok := gitReports[appName]; ok {
+			summary.SHA = git.SHA
+		}
+
+		if options != nil && options.Filter != nil && !options.Filter(summary) {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sortAppSummaries(summaries, options)
+	return summaries, nil
+}
+
+func sortAppSummaries(summaries []*AppSummary, options *AppSummaryOptions) {
+	sortBy := AppSummarySortByName
+	descending := false
+	if options != nil {
+		if options.SortBy != "" {
+			sortBy = options.SortBy
+		}
+		descending = options.Descending
+	}
+
+	compare := func(a, b *AppSummary) int {
+		switch sortBy {
+		case AppSummarySortByCreatedAt:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		case AppSummarySortByDeploySource:
+			return strings.Compare(a.DeploySource, b.DeploySource)
+		case AppSummarySortByProcessCount:
+			return a.ProcessCount() - b.ProcessCount()
+		}
+		return 0
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if descending {
+			a, b = b, a
+		}
+		if cmp := compare(a, b); cmp != 0 {
+			return cmp < 0
+		}
+		return a.Name < b.Name
+	})
+}
@@ -0,0 +1,117 @@
+package dokku
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAppSummaryTestClient() (*BaseClient, *fakeExecutor) {
+	client, fe := newFakeClient()
+	fe.on("apps:report", `=====> api information
+       App created at:                1700000200
+       App deploy source:             git
+       App locked:                    false
+=====> worker information
+       App created at:                1700000100
+       App deploy source:             docker-image
+       App locked:                    true
+=====> empty information
+       App created at:                1700000300
+       App deploy source:
+       App locked:                    false`)
+	fe.on("ps:report", `=====> api ps information
+       Deployed:                      true
+       Processes:                     3
+       Running:                       true
+       Status web 1:                  running (CID: 0a1b2c3d)
+       Status web 2:                  running (CID: 1a1b2c3d)
+       Status worker 1:               running (CID: 2a1b2c3d)
+=====> worker ps information
+       Deployed:                      true
+       Processes:                     1
+       Running:                       false
+       Status worker 1:               exited (CID: 3a1b2c3d)
+=====> empty ps information
+       Deployed:                      false
+       Processes:                     0
+       Running:                       false`)
+	fe.on("domains:report", `=====> api domains information
+       Domains app enabled:           true
+       Domains app vhosts:            api.example.com www.example.com
+=====> worker domains information
+       Domains app enabled:           false
+       Domains app vhosts:
+=====> empty domains information
+       Domains app enabled:           false
+       Domains app vhosts:`)
+	fe.on("git:report", `=====> api git information
+       Git sha:                       abc123
+=====> worker git information
+       Git sha:
+=====> empty git information
+       Git sha:`)
+	return client, fe
+}
+
+func TestListAppSummaries(t *testing.T) {
+	client, fe := newAppSummaryTestClient()
+
+	summaries, err := client.ListAppSummaries(nil)
+	require.NoError(t, err)
+	require.Len(t, summaries, 3)
+	assert.Equal(t, []string{"apps:report", "ps:report", "domains:report", "git:report"}, fe.commands)
+
+	assert.Equal(t, "api", summaries[0].Name)
+	assert.Equal(t, "empty", summaries[1].Name)
+	assert.Equal(t, "worker", summaries[2].Name)
+
+	api := summaries[0]
+	assert.Equal(t, time.Unix(1700000200, 0), api.CreatedAt)
+	assert.Equal(t, "git", api.DeploySource)
+	assert.True(t, api.Deployed)
+	assert.True(t, api.Running)
+	assert.Equal(t, map[string]int{"web": 2, "worker": 1}, api.Processes)
+	assert.Equal(t, 3, api.ProcessCount())
+	assert.Equal(t, []string{"api.example.com", "www.example.com"}, api.Domains)
+	assert.Equal(t, "abc123", api.SHA)
+
+	assert.True(t, summaries[2].Locked)
+	assert.False(t, summaries[2].Running)
+	assert.Equal(t, 0, summaries[1].ProcessCount())
+}
+
+func TestListAppSummariesOptions(t *testing.T) {
+	client, _ := newAppSummaryTestClient()
+
+	summaries, err := client.ListAppSummaries(&AppSummaryOptions{
+		SortBy:     AppSummarySortByCreatedAt,
+		Descending: true,
+	})
+	require.NoError(t, err)
+	names := []string{}
+	for _, s := range summaries {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"empty", "api", "worker"}, names)
+
+	summaries, err = client.ListAppSummaries(&AppSummaryOptions{
+		Filter: func(s *AppSummary) bool { return s.Deployed },
+		SortBy: AppSummarySortByProcessCount,
+	})
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "worker", summaries[0].Name)
+	assert.Equal(t, "api", summaries[1].Name)
+}
+
+func TestListAppSummariesNoApps(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.fail("apps:report", NoDeployedAppsError)
+
+	summaries, err := client.ListAppSummaries(nil)
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+}
@@ -0,0 +1,340 @@
+package dokku
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsSource reports the load of a process type, summed over all of its
+// containers, e.g. the total CPU percentage or requests per second.
+type MetricsSource interface {
+	ProcessMetric(ctx context.Context, appName string, process string) (float64, error)
+}
+
+type MetricsSourceFunc func(ctx context.Context, appName string, process string) (float64, error)
+
+func (f MetricsSourceFunc) ProcessMetric(ctx context.Context, appName string, process string) (float64, error) {
+	return f(ctx, appName, process)
+}
+
+// AutoscaleClient is the part of Client the autoscaler scales processes
+// with, so it works with any Client, or a test double.
+type AutoscaleClient interface {
+	GetAppProcessScale(appName string) (map[string]int, error)
+	SetAppProcessScale(appName string, processName string, scale int, skipDeploy bool) (*CommandOutputStream, error)
+}
+
+type AutoscalePolicy struct {
+	AppName string
+	Process string
+	Min     int
+	Max     int
+	// the load a single container should handle, in the unit of the
+	// metrics source
+	Target float64
+	// minimum time between two scalings of the process type
+	Cooldown time.Duration
+	// optional, defaults to 0.1. The process type isn't scaled while the
+	// load per container is within this fraction of Target.
+	Tolerance float64
+	// optional, limits how many containers are added or removed at once
+	MaxStep int
+}
+
+type AutoscalerOptions struct {
+	// optional, defaults to 30 seconds
+	Interval time.Duration
+	// decide but don't scale
+	DryRun bool
+	// optional, called with every decision, e.g. for logging
+	OnDecision func(decision AutoscaleDecision)
+}
+
+type AutoscaleDecision struct {
+	AppName string
+	Process string
+	Metric  float64
+	Current int
+	Desired int
+	Reason  string
+	Applied bool
+	Err     error
+}
+
+const (
+	defaultAutoscaleInterval  = 30 * time.Second
+	defaultAutoscaleTolerance = 0.1
+)
+
+type Autoscaler struct {
+	client   AutoscaleClient
+	metrics  MetricsSource
+	policies []AutoscalePolicy
+	options  AutoscalerOptions
+	now      func() time.Time
+
+	mu         sync.Mutex
+	lastScaled map[string]time.Time
+}
+
+func (p *AutoscalePolicy) validate() error {
+	if p.AppName == "" || p.Process == "" {
+		return errors.New("autoscale policy needs an app and process type")
+	}
+	if p.Min < 0 || p.Max < p.Min {
+		return fmt.Errorf("invalid autoscale bounds %d-%d for %s/%s", p.Min, p.Max, p.AppName, p.Process)
+	}
+	if p.Target <= 0 {
+		return fmt.Errorf("autoscale target for %s/%s must be positive", p.AppName, p.Process)
+	}
+	return nil
+}
+
+func (p *AutoscalePolicy) key() string {
+	return p.AppName + "/" + p.Process
+}
+
+func NewAutoscaler(client AutoscaleClient, metrics MetricsSource, policies []AutoscalePolicy, options *AutoscalerOptions) (*Autoscaler, error) {
+	for i := range policies {
+		if err := policies[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	a := &Autoscaler{
+		client:     client,
+		metrics:    metrics,
+		policies:   policies,
+		now:        time.Now,
+		lastScaled: map[string]time.Time{},
+	}
+	if options != nil {
+		a.options = *options
+	}
+	if a.options.Interval <= 0 {
+		a.options.Interval = defaultAutoscaleInterval
+	}
+	return a, nil
+}
+
+// decideScale returns the number of containers the process type should run
+// and why.
+func decideScale(policy AutoscalePolicy, current int, metric float64) (int, string) {
+	if current < policy.Min {
+		return policy.Min, "below minimum"
+	}
+	if current > policy.Max {
+		return policy.Max, "above maximum"
+	}
+
+	tolerance := policy.Tolerance
+	if tolerance <= 0 {
+		tolerance = defaultAutoscaleTolerance
+	}
+	if current > 0 {
+		ratio := metric / (float64(current) * policy.Target)
+		if math.Abs(ratio-1) <= tolerance {
+			return current, "within tolerance"
+		}
+	}
+
+	desired := int(math.Ceil(metric / policy.Target))
+	if desired < policy.Min {
+		desired = policy.Min
+	}
+	if desired > policy.Max {
+		desired = policy.Max
+	}
+	if policy.MaxStep > 0 {
+		if desired > current+policy.MaxStep {
+			desired = current + policy.MaxStep
+		} else if desired < current-policy.MaxStep {
+			desired = current - policy.MaxStep
+		}
+	}
+
+	switch {
+	case desired > current:
+		return desired, "load above target"
+	case desired < current:
+		return desired, "load below target"
+	}
+	return current, "at bounds"
+}
+
+// Evaluate makes a scaling decision for every policy, and applies it unless
+// running in dry-run mode. A failing policy doesn't stop the others.
+func (a *Autoscaler) Evaluate(ctx context.Context) ([]AutoscaleDecision, error) {
+	scales := map[string]map[string]int{}
+	decisions := make([]AutoscaleDecision, 0, len(a.policies))
+	var errs []error
+
+	for _, policy := range a.policies {
+		decision := a.evaluatePolicy(ctx, policy, scales)
+		if decision.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", policy.key(), decision.Err))
+		}
+		if a.options.OnDecision != nil {
+			a.options.OnDecision(decision)
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, errors.Join(errs...)
+}
+
+func (a *Autoscaler) evaluatePolicy(ctx context.Context, policy AutoscalePolicy, scales map[string]map[string]int) AutoscaleDecision {
+	decision := AutoscaleDecision{AppName: policy.AppName, Process: policy.Process}
+
+	scale, ok := scales[policy.AppName]
+	if !ok {
+		var err error
+		if scale, err = a.client.GetAppProcessScale(policy.AppName); err != nil {
+			decision.Err = err
+			return decision
+		}
+		scales[policy.AppName] = scale
+	}
+	decision.Current = scale[policy.Process]
+	decision.Desired = decision.Current
+
+	metric, err := a.metrics.ProcessMetric(ctx, policy.AppName, policy.Process)
+	if err != nil {
+		decision.Err = fmt.Errorf("failed to get metric: %w", err)
+		return decision
+	}
+	decision.Metric = metric
+	decision.Desired, decision.Reason = decideScale(policy, decision.Current, metric)
+	if decision.Desired == decision.Current {
+		return decision
+	}
+
+	a.mu.Lock()
+	last, scaledBefore := a.lastScaled[policy.key()]
+	a.mu.Unlock()
+	if scaledBefore && a.now().Sub(last) < policy.Cooldown {
+		decision.Reason += ", cooling down"
+		return decision
+	}
+	if a.options.DryRun {
+		decision.Reason += ", dry run"
+		return decision
+	}
+
+	stream, err := a.client.SetAppProcessScale(policy.AppName, policy.Process, decision.Desired, false)
+	if err == nil {
+		err = stream.Wait()
+	}
+	if err != nil {
+		decision.Err = fmt.Errorf("failed to scale: %w", err)
+		return decision
+	}
+	decision.Applied = true
+	scale[policy.Process] = decision.Desired
+
+	a.mu.Lock()
+	a.lastScaled[policy.key()] = a.now()
+	a.mu.Unlock()
+	return decision
+}
+
+// Run evaluates the policies every interval until ctx is done. Evaluation
+// errors are reported through OnDecision and don't stop the autoscaler.
+func (a *Autoscaler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.options.Interval)
+	defer ticker.Stop()
+	for {
+		_, _ = a.Evaluate(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// NginxRequestRateSource measures the requests per second of the web process
+// from the app's nginx access log, which must use a format with the
+// [02/Jan/2006:15:04:05 -0700] timestamp, like the default combined format.
+// Only the lines returned by nginx:access-logs are seen, so high rates over
+// long windows are underestimated.
+type NginxRequestRateSource struct {
+	Client *BaseClient
+	// optional, defaults to one minute
+	Window time.Duration
+
+	now func() time.Time
+}
+
+const nginxLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+func (s *NginxRequestRateSource) ProcessMetric(ctx context.Context, appName string, process string) (float64, error) {
+	logs, err := s.Client.GetAppNginxAccessLogs(appName)
+	if err != nil {
+		return 0, err
+	}
+	window := s.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	return nginxRequestRate(logs, now().Add(-window), window), nil
+}
+
+func nginxRequestRate(logs string, since time.Time, window time.Duration) float64 {
+	count := 0
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	for scanner.Scan() {
+		line := scanner.Text()
+		start := strings.IndexByte(line, '[')
+		end := strings.IndexByte(line, ']')
+		if start < 0 || end < start {
+			continue
+		}
+		ts, err := time.Parse(nginxLogTimeFormat, line[start+1:end])
+		if err != nil {
+			continue
+		}
+		if !ts.Before(since) {
+			count++
+		}
+	}
+	return float64(count) / window.Seconds()
+}
+
+// CommandMetricsSource runs a command in a one-off container of the app with
+// RunAppCommand and reads the metric from the last line of its output. The
+// command is run with the process type in the DOKKU_METRIC_PROCESS variable.
+//
+// The one-off container is separate from the app's running containers and
+// has no access to the docker daemon, so it can't measure them itself, e.g.
+// with docker stats. The command has to fetch the metric from wherever the
+// app reports it, like a metrics endpoint, a queue length or a database.
+type CommandMetricsSource struct {
+	Client  *BaseClient
+	Command string
+}
+
+func (s *CommandMetricsSource) ProcessMetric(ctx context.Context, appName string, process string) (float64, error) {
+	out, err := s.Client.RunAppCommand(appName, s.Command, &DockerRunOptions{
+		Environment: map[string]string{"DOKKU_METRIC_PROCESS": process},
+	})
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	metric, err := strconv.ParseFloat(last, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid metric '%s'", last)
+	}
+	return metric, nil
+}
@@ -0,0 +1,161 @@
+package dokku
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMetricsSource map[string]float64
+
+func (m fakeMetricsSource) ProcessMetric(ctx context.Context, appName string, process string) (float64, error) {
+	metric, ok := m[appName+"/"+process]
+	if !ok {
+		return 0, fmt.Errorf("no metric for %s/%s", appName, process)
+	}
+	return metric, nil
+}
+
+func TestDecideScale(t *testing.T) {
+	policy := AutoscalePolicy{Min: 1, Max: 10, Target: 50}
+
+	cases := []struct {
+		current int
+		metric  float64
+		desired int
+	}{
+		{2, 100, 2},   // exactly on target
+		{2, 108, 2},   // within tolerance
+		{2, 150, 3},   // above target
+		{4, 90, 2},    // below target
+		{2, 5000, 10}, // capped at max
+		{3, 0, 1},     // floored at min
+		{0, 0, 1},     // below min
+		{12, 600, 10}, // above max
+	}
+	for _, c := range cases {
+		desired, _ := decideScale(policy, c.current, c.metric)
+		assert.Equal(t, c.desired, desired, "current=%d metric=%f", c.current, c.metric)
+	}
+
+	policy.MaxStep = 1
+	desired, _ := decideScale(policy, 2, 500)
+	assert.Equal(t, 3, desired)
+}
+
+func TestAutoscalerEvaluate(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:scale api", "-----> Scaling for api\nproctype: qty\n--------: ---\nweb: 2\nworker: 1")
+	metrics := fakeMetricsSource{"api/web": 200, "api/worker": 10}
+
+	var decisions []AutoscaleDecision
+	scaler, err := NewAutoscaler(client, metrics, []AutoscalePolicy{
+		{AppName: "api", Process: "web", Min: 1, Max: 5, Target: 50, Cooldown: time.Minute},
+		{AppName: "api", Process: "worker", Min: 1, Max: 3, Target: 10},
+	}, &AutoscalerOptions{OnDecision: func(d AutoscaleDecision) { decisions = append(decisions, d) }})
+	require.NoError(t, err)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	scaler.now = func() time.Time { return now }
+
+	result, err := scaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, result, decisions)
+	require.Len(t, result, 2)
+	assert.True(t, result[0].Applied)
+	assert.Equal(t, 4, result[0].Desired)
+	assert.False(t, result[1].Applied)
+	assert.Equal(t, []string{"ps:scale api", "ps:scale api web=4"}, fe.commands)
+
+	// the scale table still says 2, but the cooldown keeps it from scaling again
+	result, err = scaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	assert.False(t, result[0].Applied)
+	assert.Contains(t, result[0].Reason, "cooling down")
+
+	now = now.Add(2 * time.Minute)
+	result, err = scaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result[0].Applied)
+}
+
+func TestAutoscalerDryRunAndErrors(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:scale api", "web: 1")
+
+	scaler, err := NewAutoscaler(client, fakeMetricsSource{"api/web": 500}, []AutoscalePolicy{
+		{AppName: "api", Process: "web", Min: 1, Max: 5, Target: 50},
+		{AppName: "api", Process: "worker", Min: 0, Max: 2, Target: 10},
+	}, &AutoscalerOptions{DryRun: true})
+	require.NoError(t, err)
+
+	result, err := scaler.Evaluate(context.Background())
+	assert.ErrorContains(t, err, "api/worker")
+	assert.False(t, result[0].Applied)
+	assert.Equal(t, 5, result[0].Desired)
+	assert.Contains(t, result[0].Reason, "dry run")
+	assert.Error(t, result[1].Err)
+	assert.Equal(t, []string{"ps:scale api"}, fe.commands)
+
+	_, err = NewAutoscaler(client, nil, []AutoscalePolicy{{AppName: "api", Process: "web", Min: 3, Max: 1, Target: 1}}, nil)
+	assert.Error(t, err)
+}
+
+// fakeScaleClient keeps process scales in memory.
+type fakeScaleClient map[string]map[string]int
+
+func (c fakeScaleClient) GetAppProcessScale(appName string) (map[string]int, error) {
+	scale := map[string]int{}
+	for process, count := range c[appName] {
+		scale[process] = count
+	}
+	return scale, nil
+}
+
+func (c fakeScaleClient) SetAppProcessScale(appName string, processName string, scale int, skipDeploy bool) (*CommandOutputStream, error) {
+	c[appName][processName] = scale
+	return &CommandOutputStream{}, nil
+}
+
+func TestAutoscalerWithClientDouble(t *testing.T) {
+	client := fakeScaleClient{"api": {"web": 4}}
+	scaler, err := NewAutoscaler(client, fakeMetricsSource{"api/web": 40}, []AutoscalePolicy{
+		{AppName: "api", Process: "web", Min: 1, Max: 5, Target: 20},
+	}, nil)
+	require.NoError(t, err)
+
+	result, err := scaler.Evaluate(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result[0].Applied)
+	assert.Equal(t, 2, client["api"]["web"])
+}
+
+func TestNginxRequestRateSource(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("nginx:access-logs api", `10.0.0.1 - - [19/Oct/2026:11:58:00 +0000] "GET / HTTP/1.1" 200 12 "-" "curl"
+10.0.0.1 - - [19/Oct/2026:11:59:30 +0000] "GET / HTTP/1.1" 200 12 "-" "curl"
+garbage line
+10.0.0.1 - - [19/Oct/2026:11:59:45 +0000] "GET / HTTP/1.1" 200 12 "-" "curl"`)
+
+	source := &NginxRequestRateSource{
+		Client: client,
+		Window: time.Minute,
+		now:    func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) },
+	}
+	rate, err := source.ProcessMetric(context.Background(), "api", "web")
+	require.NoError(t, err)
+	assert.InDelta(t, 2.0/60, rate, 0.0001)
+}
+
+func TestCommandMetricsSource(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("run --env 'DOKKU_METRIC_PROCESS=web' --no-tty api /bin/probe", "starting probe\n42.5")
+
+	source := &CommandMetricsSource{Client: client, Command: "/bin/probe"}
+	metric, err := source.ProcessMetric(context.Background(), "api", "web")
+	require.NoError(t, err)
+	assert.Equal(t, 42.5, metric)
+}
@@ -0,0 +1,170 @@
+package dokku
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BuildArgsLeakedError is returned when build args are also set as runtime
+// config or docker options of the app, so their values end up in the
+// running containers.
+type BuildArgsLeakedError struct {
+	AppName string
+	Keys    []string
+}
+
+func (e *BuildArgsLeakedError) Error() string {
+	return fmt.Sprintf("build args of app '%s' leak into its runtime config: %s", e.AppName, strings.Join(e.Keys, ", "))
+}
+
+const (
+	dockerOptionsBuildPhase = "build"
+	buildArgFlag            = "--build-arg"
+
+	// disables the docker build cache of the dockerfile builder when false
+	dockerfileCacheBuildKey = "DOKKU_DOCKERFILE_CACHE_BUILD"
+)
+
+// parseBuildArgs returns the build args of a build phase docker-options line,
+// keyed by build arg name, with the option each was set by.
+func parseBuildArgs(buildOptions string) (args map[string]string, options map[string]string) {
+	args = map[string]string{}
+	options = map[string]string{}
+	for _, option := range splitDockerOptions(buildOptions) {
+		var arg string
+		if strings.HasPrefix(option, buildArgFlag+"=") {
+			arg = strings.TrimPrefix(option, buildArgFlag+"=")
+		} else if strings.HasPrefix(option, buildArgFlag+" ") {
+			arg = strings.TrimSpace(strings.TrimPrefix(option, buildArgFlag))
+		} else {
+			continue
+		}
+		key, value, _ := strings.Cut(arg, "=")
+		args[key] = value
+		options[key] = option
+	}
+	return args, options
+}
+
+func buildArgOption(key string, value string) (string, error) {
+	if err := validateConfigKey(key); err != nil {
+		return "", err
+	}
+	// docker options are stored as a single whitespace separated line
+	if strings.ContainsAny(value, " \t\r\n'\"") {
+		return "", fmt.Errorf("build arg '%s' can't contain whitespace or quotes", key)
+	}
+	return fmt.Sprintf("%s %s=%s", buildArgFlag, key, value), nil
+}
+
+func (c *BaseClient) GetAppBuildArgs(appName string) (map[string]string, error) {
+	report, err := c.GetAppDockerOptionsReport(appName)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := parseBuildArgs(report.BuildOptions)
+	return args, nil
+}
+
+// SetAppBuildArgs makes args the app's --build-arg docker options of the
+// build phase. Only changed build args are removed and added, other build
+// options are kept.
+func (c *BaseClient) SetAppBuildArgs(appName string, args map[string]string) error {
+	desired := map[string]string{}
+	for _, key := range sortedKeys(args) {
+		option, err := buildArgOption(key, args[key])
+		if err != nil {
+			return err
+		}
+		desired[key] = option
+	}
+
+	report, err := c.GetAppDockerOptionsReport(appName)
+	if err != nil {
+		return err
+	}
+	current, currentOptions := parseBuildArgs(report.BuildOptions)
+
+	for _, key := range sortedKeys(currentOptions) {
+		if value, ok := args[key]; ok && value == current[key] {
+			continue
+		}
+		if err := c.RemoveAppPhaseDockerOption(appName, dockerOptionsBuildPhase, currentOptions[key]); err != nil {
+			return fmt.Errorf("failed to remove build arg '%s': %w", key, err)
+		}
+	}
+	for _, key := range sortedKeys(desired) {
+		if value, ok := current[key]; ok && value == args[key] {
+			continue
+		}
+		if err := c.AddAppPhaseDockerOption(appName, dockerOptionsBuildPhase, desired[key]); err != nil {
+			return fmt.Errorf("failed to add build arg '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+// SetAppDockerfileCacheBuild enables or disables the docker build cache for
+// apps built by the dockerfile builder. It takes effect on the next build.
+func (c *BaseClient) SetAppDockerfileCacheBuild(appName string, enabled bool) error {
+	return c.SetAppConfigValue(appName, dockerfileCacheBuildKey, strconv.FormatBool(enabled), false)
+}
+
+// CheckAppBuildArgs returns a BuildArgsLeakedError if a build arg of the app
+// is also set in its runtime config, or as an env option of the deploy or
+// run phase.
+func (c *BaseClient) CheckAppBuildArgs(appName string) error {
+	report, err := c.GetAppDockerOptionsReport(appName)
+	if err != nil {
+		return err
+	}
+	args, _ := parseBuildArgs(report.BuildOptions)
+	if len(args) == 0 {
+		return nil
+	}
+
+	config, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return err
+	}
+	runtimeKeys := map[string]bool{}
+	for key := range config {
+		runtimeKeys[key] = true
+	}
+	for _, options := range []string{report.DeployOptions, report.RunOptions} {
+		for _, key := range envOptionKeys(options) {
+			runtimeKeys[key] = true
+		}
+	}
+
+	leaked := []string{}
+	for _, key := range sortedKeys(args) {
+		if runtimeKeys[key] {
+			leaked = append(leaked, key)
+		}
+	}
+	if len(leaked) > 0 {
+		return &BuildArgsLeakedError{AppName: appName, Keys: leaked}
+	}
+	return nil
+}
+
+// envOptionKeys returns the keys set by -e and --env docker options.
+func envOptionKeys(options string) []string {
+	keys := []string{}
+	for _, option := range splitDockerOptions(options) {
+		var env string
+		switch {
+		case strings.HasPrefix(option, "-e "), strings.HasPrefix(option, "--env "):
+			_, env, _ = strings.Cut(option, " ")
+		case strings.HasPrefix(option, "-e="), strings.HasPrefix(option, "--env="):
+			_, env, _ = strings.Cut(option, "=")
+		default:
+			continue
+		}
+		key, _, _ := strings.Cut(strings.Trim(env, `'"`), "=")
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,60 @@
+package dokku
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDockerOptionsReport = `=====> api docker options information
+       Docker options build:          --build-arg NODE_ENV=production --pull --build-arg=API_TOKEN=abc
+       Docker options deploy:         --restart=on-failure:10 -e API_TOKEN=abc
+       Docker options run:`
+
+func TestParseBuildArgs(t *testing.T) {
+	args, options := parseBuildArgs("--build-arg NODE_ENV=production --pull --build-arg=API_TOKEN=a=b --build-arg EMPTY=")
+	assert.Equal(t, map[string]string{"NODE_ENV": "production", "API_TOKEN": "a=b", "EMPTY": ""}, args)
+	assert.Equal(t, "--build-arg=API_TOKEN=a=b", options["API_TOKEN"])
+	assert.Equal(t, "--build-arg NODE_ENV=production", options["NODE_ENV"])
+}
+
+func TestSetAppBuildArgs(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("docker-options:report api", testDockerOptionsReport)
+
+	require.NoError(t, client.SetAppBuildArgs("api", map[string]string{
+		"NODE_ENV": "production",
+		"VERSION":  "1.2.0",
+	}))
+	assert.Equal(t, []string{
+		"docker-options:report api",
+		"docker-options:remove api build --build-arg=API_TOKEN=abc",
+		"docker-options:add api build --build-arg VERSION=1.2.0",
+	}, fe.commands)
+
+	assert.Error(t, client.SetAppBuildArgs("api", map[string]string{"NODE_ENV": "two words"}))
+	assert.Error(t, client.SetAppBuildArgs("api", map[string]string{"NODE ENV": "x"}))
+}
+
+func TestCheckAppBuildArgs(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("docker-options:report api", testDockerOptionsReport)
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{"NODE_ENV": "production", "PORT": "5000"}))
+
+	err := client.CheckAppBuildArgs("api")
+	var leakedErr *BuildArgsLeakedError
+	require.ErrorAs(t, err, &leakedErr)
+	assert.Equal(t, []string{"API_TOKEN", "NODE_ENV"}, leakedErr.Keys)
+
+	fe.on("docker-options:report api", "=====> api docker options information\n       Docker options build:          --pull")
+	assert.NoError(t, client.CheckAppBuildArgs("api"))
+}
+
+func TestSetAppDockerfileCacheBuild(t *testing.T) {
+	client, fe := newFakeClient()
+	require.NoError(t, client.SetAppDockerfileCacheBuild("api", false))
+	assert.Len(t, fe.commands, 1)
+	assert.Contains(t, fe.commands[0], "DOKKU_DOCKERFILE_CACHE_BUILD")
+	assert.Contains(t, fe.commands[0], "--no-restart")
+}
@@ -1,6 +1,7 @@
 package dokku
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -9,6 +10,7 @@ import (
 
 type builderManager interface {
 	GetAppBuilderReport(appName string) (*AppBuilderReport, error)
+	DetectAppBuilder(appName string) (AppBuilder, error)
 	SetAppBuilderProperty(appName string, property BuilderProperty, value string) error
 	SetAppSelectedBuilder(appName string, builder AppBuilder) error
 
@@ -21,6 +23,7 @@ type builderManager interface {
 	AddAppBuildpack(appName string, buildpack string) error
 	ClearAppBuildpacks(appName string) error
 	ListAppBuildpacks(appName string) ([]string, error)
+	SetAppBuildpackList(appName string, buildpacks []BuildpackRef) error
 	RemoveAppBuildpack(appName string, buildpack string) error
 	GetAppBuildpacksReport(appName string) (*AppBuildpacksReport, error)
 	SetAppBuildpack(appName string, buildpack string) error
@@ -29,6 +32,14 @@ type builderManager interface {
 
 	SetAppLambdaBuilderProperty(appName string, property LambdaBuilderProperty, value string) error
 	SetGlobalLambdaBuilderProperty(property LambdaBuilderProperty, value string) error
+
+	GetAppBuilderNixpacksReport(appName string) (*AppBuilderNixpacksReport, error)
+	SetAppBuilderNixpacksProperty(appName string, property NixpacksProperty, value string) error
+	SetGlobalBuilderNixpacksProperty(property NixpacksProperty, value string) error
+
+	GetAppBuilderRailpackReport(appName string) (*AppBuilderRailpackReport, error)
+	SetAppBuilderRailpackProperty(appName string, property RailpackProperty, value string) error
+	SetGlobalBuilderRailpackProperty(property RailpackProperty, value string) error
 }
 
 type (
@@ -58,6 +69,16 @@ type (
 
 		List string `dokku:"Buildpacks list"`
 	}
+	AppBuilderNixpacksReport struct {
+		NixpacksTOMLPath         string `dokku:"Builder-nixpacks nixpackstoml path"`
+		ComputedNixpacksTOMLPath string `dokku:"Builder-nixpacks computed nixpackstoml path"`
+		GlobalNixpacksTOMLPath   string `dokku:"Builder-nixpacks global nixpackstoml path"`
+	}
+	AppBuilderRailpackReport struct {
+		RailpackJSONPath         string `dokku:"Builder-railpack railpackjson path"`
+		ComputedRailpackJSONPath string `dokku:"Builder-railpack computed railpackjson path"`
+		GlobalRailpackJSONPath   string `dokku:"Builder-railpack global railpackjson path"`
+	}
 	AppLambdaBuilderReport struct {
 		ComputedLambdaYmlPath string `dokku:"Builder-lambda computed lambdayml path"`
 		GlobalLambdaYmlPath   string `dokku:"Builder-lambda global lambdayml path"`
@@ -69,14 +90,18 @@ type (
 	BuildpackProperty     string
 	DockerfileProperty    string
 	LambdaBuilderProperty string
+	NixpacksProperty      string
+	RailpackProperty      string
 )
 
 const (
 	AppBuilderDockerfile = AppBuilder("dockerfile")
 	AppBuilderHerokuish  = AppBuilder("herokuish")
 	AppBuilderLambda     = AppBuilder("lambda")
+	AppBuilderNixpacks   = AppBuilder("nixpacks")
 	AppBuilderNull       = AppBuilder("null")
 	AppBuilderPack       = AppBuilder("pack")
+	AppBuilderRailpack   = AppBuilder("railpack")
 
 	BuilderPropertySelected = BuilderProperty("selected")
 	BuilderPropertyBuildDir = BuilderProperty("build-dir")
@@ -87,6 +112,10 @@ const (
 	DockerfilePropertyPath = DockerfileProperty("dockerfile-path")
 
 	LambdaBuilderPropertyYml = DockerfileProperty("lambdayml-path")
+
+	NixpacksPropertyTOMLPath = NixpacksProperty("nixpackstoml-path")
+
+	RailpackPropertyJSONPath = RailpackProperty("railpackjson-path")
 )
 
 const (
@@ -109,8 +138,19 @@ const (
 
 	builderLambdaReportCmd      = "builder-lambda:report %s"
 	builderLambdaSetPropertyCmd = "builder-lambda:set %s %s %s"
+
+	builderNixpacksReportCmd      = "builder-nixpacks:report %s"
+	builderNixpacksSetPropertyCmd = "builder-nixpacks:set %s %s %s"
+
+	builderRailpackReportCmd      = "builder-railpack:report %s"
+	builderRailpackSetPropertyCmd = "builder-railpack:set %s %s %s"
+
+	// set by dokku on the images it builds
+	dokkuBuilderTypeLabel = "com.dokku.builder-type"
 )
 
+var BuilderNotDetectedError = errors.New("builder of the deployed image is unknown")
+
 func (c *BaseClient) GetAppBuilderReport(appName string) (*AppBuilderReport, error) {
 	cmd := fmt.Sprintf(builderReportCmd, appName)
 	out, err := c.Exec(cmd)
@@ -123,7 +163,31 @@ func (c *BaseClient) GetAppBuilderReport(appName string) (*AppBuilderReport, err
 		return nil, err
 	}
 
-	return &report, err
+	return &report, nil
+}
+
+// DetectAppBuilder returns the builder of the deployed image, read from the
+// labels of the app's containers with ps:inspect. It returns
+// AppNotDeployedError if the app isn't deployed, and BuilderNotDetectedError
+// if no container has the label, e.g. for images built by older dokku
+// versions.
+func (c *BaseClient) DetectAppBuilder(appName string) (AppBuilder, error) {
+	containers, err := c.GetProcessInfo(appName)
+	if err != nil {
+		return "", err
+	}
+	for _, container := range containers {
+		if builder := container.Labels[dokkuBuilderTypeLabel]; builder != "" {
+			return AppBuilder(builder), nil
+		}
+	}
+	return "", BuilderNotDetectedError
+}
+
+// AutoDetected reports whether dokku detects the builder on deploy, because
+// none was selected for the app or globally.
+func (r *AppBuilderReport) AutoDetected() bool {
+	return r.ComputedSelectedBuilder == ""
 }
 
 func (c *BaseClient) SetAppBuilderProperty(appName string, property BuilderProperty, value string) error {
@@ -199,16 +263,21 @@ func (c *BaseClient) ClearAppBuildpacks(appName string) error {
 func (c *BaseClient) ListAppBuildpacks(appName string) ([]string, error) {
 	cmd := fmt.Sprintf(buildpacksListCmd, appName)
 	out, err := c.Exec(cmd)
+	if err != nil {
+		return nil, err
+	}
 
-	var packs []string
-	for i, line := range strings.Split(out, "\n") {
-		if i == 0 {
+	packs := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		// skip the heading
+		if line == "" || strings.HasPrefix(line, "=====>") || strings.HasPrefix(line, "----->") {
 			continue
 		}
 		packs = append(packs, line)
 	}
 
-	return packs, err
+	return packs, nil
 }
 
 func (c *BaseClient) RemoveAppBuildpack(appName string, buildpack string) error {
@@ -278,3 +347,53 @@ func (c *BaseClient) GetAppLambdaBuilderReport(appName string) (*AppLambdaBuilde
 
 	return &report, err
 }
+
+func (c *BaseClient) GetAppBuilderNixpacksReport(appName string) (*AppBuilderNixpacksReport, error) {
+	cmd := fmt.Sprintf(builderNixpacksReportCmd, appName)
+	out, err := c.Exec(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var report AppBuilderNixpacksReport
+	if err := reports.ParseInto(out, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, err
+}
+
+func (c *BaseClient) SetAppBuilderNixpacksProperty(appName string, property NixpacksProperty, value string) error {
+	cmd := fmt.Sprintf(builderNixpacksSetPropertyCmd, appName, property, value)
+	_, err := c.Exec(cmd)
+	return err
+}
+
+func (c *BaseClient) SetGlobalBuilderNixpacksProperty(property NixpacksProperty, value string) error {
+	return c.SetAppBuilderNixpacksProperty("--global", property, value)
+}
+
+func (c *BaseClient) GetAppBuilderRailpackReport(appName string) (*AppBuilderRailpackReport, error) {
+	cmd := fmt.Sprintf(builderRailpackReportCmd, appName)
+	out, err := c.Exec(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var report AppBuilderRailpackReport
+	if err := reports.ParseInto(out, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, err
+}
+
+func (c *BaseClient) SetAppBuilderRailpackProperty(appName string, property RailpackProperty, value string) error {
+	cmd := fmt.Sprintf(builderRailpackSetPropertyCmd, appName, property, value)
+	_, err := c.Exec(cmd)
+	return err
+}
+
+func (c *BaseClient) SetGlobalBuilderRailpackProperty(property RailpackProperty, value string) error {
+	return c.SetAppBuilderRailpackProperty("--global", property, value)
+}
@@ -1,8 +1,11 @@
 package dokku
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -28,3 +31,62 @@ func (s *builderManagerTestSuite) TestReports() {
 	r.NoError(err2)
 	r.Equal("project.toml", report2.GlobalProjectTOMLPath)
 }
+
+func TestGetAppBuilderReportAutoDetected(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("builder:report api", "=====> api builder information\n       Builder selected:\n       Builder computed selected:\n       Builder global selected:")
+
+	report, err := client.GetAppBuilderReport("api")
+	require.NoError(t, err)
+	assert.True(t, report.AutoDetected())
+	assert.Equal(t, []string{"builder:report api"}, fe.commands, "detection is a separate call")
+
+	fe.on("builder:report api", "=====> api builder information\n       Builder selected: railpack\n       Builder computed selected: railpack")
+	report, err = client.GetAppBuilderReport("api")
+	require.NoError(t, err)
+	assert.False(t, report.AutoDetected())
+}
+
+func TestDetectAppBuilder(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:inspect api", `[{"Id": "03ea", "Config": {"Labels": {"com.dokku.builder-type": "nixpacks"}}}]`)
+	builder, err := client.DetectAppBuilder("api")
+	require.NoError(t, err)
+	assert.Equal(t, AppBuilderNixpacks, builder)
+
+	fe.on("ps:inspect api", `[{"Id": "03ea", "Config": {"Labels": {}}}]`)
+	_, err = client.DetectAppBuilder("api")
+	assert.ErrorIs(t, err, BuilderNotDetectedError)
+
+	fe.fail("ps:inspect api", errors.New("exit status 1"))
+	fe.on("ps:inspect api", psNotDeployedMsg)
+	_, err = client.DetectAppBuilder("api")
+	assert.ErrorIs(t, err, AppNotDeployedError)
+}
+
+func TestBuilderNixpacksAndRailpack(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("builder-nixpacks:report api", "=====> api builder-nixpacks information\n       Builder-nixpacks computed nixpackstoml path: nixpacks.toml\n       Builder-nixpacks global nixpackstoml path: nixpacks.toml\n       Builder-nixpacks nixpackstoml path:")
+	fe.on("builder-railpack:report api", "=====> api builder-railpack information\n       Builder-railpack computed railpackjson path: config/railpack.json\n       Builder-railpack railpackjson path: config/railpack.json")
+
+	nixpacks, err := client.GetAppBuilderNixpacksReport("api")
+	require.NoError(t, err)
+	assert.Equal(t, "nixpacks.toml", nixpacks.ComputedNixpacksTOMLPath)
+	assert.Equal(t, "nixpacks.toml", nixpacks.GlobalNixpacksTOMLPath)
+	assert.Empty(t, nixpacks.NixpacksTOMLPath)
+
+	railpack, err := client.GetAppBuilderRailpackReport("api")
+	require.NoError(t, err)
+	assert.Equal(t, "config/railpack.json", railpack.RailpackJSONPath)
+
+	require.NoError(t, client.SetAppBuilderNixpacksProperty("api", NixpacksPropertyTOMLPath, "build/nixpacks.toml"))
+	require.NoError(t, client.SetGlobalBuilderRailpackProperty(RailpackPropertyJSONPath, "railpack.json"))
+	require.NoError(t, client.SetAppSelectedBuilder("api", AppBuilderRailpack))
+	assert.Equal(t, []string{
+		"builder-nixpacks:report api",
+		"builder-railpack:report api",
+		"builder-nixpacks:set api nixpackstoml-path build/nixpacks.toml",
+		"builder-railpack:set --global railpackjson-path railpack.json",
+		"builder:set api selected railpack",
+	}, fe.commands)
+}
@@ -0,0 +1,223 @@
+package dokku
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type BuildpackRefKind string
+
+const (
+	// e.g. heroku/nodejs
+	BuildpackRefShortName = BuildpackRefKind("short-name")
+	// e.g. https://github.com/heroku/heroku-buildpack-nodejs.git#v200
+	BuildpackRefGit = BuildpackRefKind("git")
+	// e.g. urn:cnb:registry:heroku/nodejs, docker://heroku/buildpack-nodejs
+	// or heroku/nodejs@1.0.0
+	BuildpackRefCNB = BuildpackRefKind("cnb")
+)
+
+// BuildpackRef is a buildpack as listed by buildpacks:list.
+type BuildpackRef struct {
+	Kind BuildpackRefKind
+	// the buildpack without the git ref
+	URL string
+	// optional, the git branch, tag or commit after the '#'
+	Ref string
+}
+
+var InvalidBuildpackRefError = errors.New("invalid buildpack")
+
+var (
+	buildpackShortNameRe = regexp.MustCompile(`^[a-zA-Z0-9][\w.-]*/[\w.-]+$`)
+	buildpackCNBIDRe     = regexp.MustCompile(`^[a-zA-Z0-9][\w.-]*/[\w.-]+@[\w.+-]+$`)
+	scpLikeGitURLRe      = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/]`)
+	gitRefRe             = regexp.MustCompile(`^[\w./-]+$`)
+
+	gitURLSchemes = map[string]bool{"http": true, "https": true, "git": true, "ssh": true, "file": true}
+)
+
+const (
+	cnbURNPrefix    = "urn:cnb:"
+	cnbDockerPrefix = "docker://"
+)
+
+func invalidBuildpackRef(buildpack string, reason string) error {
+	return fmt.Errorf("%w '%s': %s", InvalidBuildpackRefError, buildpack, reason)
+}
+
+// ParseBuildpackRef parses a buildpack short name, git URL with an optional
+// #ref, or cloud native buildpack URI.
+func ParseBuildpackRef(buildpack string) (BuildpackRef, error) {
+	if buildpack == "" {
+		return BuildpackRef{}, invalidBuildpackRef(buildpack, "empty")
+	}
+	if strings.ContainsAny(buildpack, " \t\r\n") {
+		return BuildpackRef{}, invalidBuildpackRef(buildpack, "contains whitespace")
+	}
+
+	switch {
+	case strings.HasPrefix(buildpack, cnbURNPrefix), strings.HasPrefix(buildpack, cnbDockerPrefix):
+		rest := strings.TrimPrefix(strings.TrimPrefix(buildpack, cnbURNPrefix), cnbDockerPrefix)
+		if rest == "" || strings.HasSuffix(rest, ":") {
+			return BuildpackRef{}, invalidBuildpackRef(buildpack, "missing buildpack id")
+		}
+		return BuildpackRef{Kind: BuildpackRefCNB, URL: buildpack}, nil
+	case buildpackCNBIDRe.MatchString(buildpack):
+		return BuildpackRef{Kind: BuildpackRefCNB, URL: buildpack}, nil
+	case buildpackShortNameRe.MatchString(buildpack):
+		return BuildpackRef{Kind: BuildpackRefShortName, URL: buildpack}, nil
+	}
+
+	gitURL, ref, hasRef := strings.Cut(buildpack, "#")
+	if hasRef && !gitRefRe.MatchString(ref) {
+		return BuildpackRef{}, invalidBuildpackRef(buildpack, "invalid git ref")
+	}
+	if !scpLikeGitURLRe.MatchString(gitURL) {
+		u, err := url.Parse(gitURL)
+		if err != nil || !gitURLSchemes[u.Scheme] {
+			return BuildpackRef{}, invalidBuildpackRef(buildpack, "not a short name, git URL or CNB URI")
+		}
+		if u.Scheme != "file" && u.Host == "" {
+			return BuildpackRef{}, invalidBuildpackRef(buildpack, "missing host")
+		}
+		if strings.Trim(u.Path, "/") == "" {
+			return BuildpackRef{}, invalidBuildpackRef(buildpack, "missing repository path")
+		}
+	}
+	return BuildpackRef{Kind: BuildpackRefGit, URL: gitURL, Ref: ref}, nil
+}
+
+func (r BuildpackRef) String() string {
+	if r.Ref != "" {
+		return r.URL + "#" + r.Ref
+	}
+	return r.URL
+}
+
+type buildpackChange struct {
+	// "add" or "remove"
+	action    string
+	buildpack string
+	// 1-based index for additions
+	index int
+}
+
+// buildpackListChanges returns the removals and additions which turn current
+// into desired. Buildpacks which are already in the right relative order are
+// kept, everything else is removed and added at its new index.
+func buildpackListChanges(current []string, desired []string) []buildpackChange {
+	position := map[string]int{}
+	for i, buildpack := range desired {
+		position[buildpack] = i
+	}
+
+	// the buildpacks of current which are also desired, as positions in desired
+	kept := []string{}
+	positions := []int{}
+	for _, buildpack := range current {
+		if i, ok := position[buildpack]; ok {
+			kept = append(kept, buildpack)
+			positions = append(positions, i)
+		}
+	}
+	inOrder := map[string]bool{}
+	for _, i := range longestIncreasingSubsequence(positions) {
+		inOrder[kept[i]] = true
+	}
+
+	changes := []buildpackChange{}
+	for _, buildpack := range current {
+		if !inOrder[buildpack] {
+			changes = append(changes, buildpackChange{action: "remove", buildpack: buildpack})
+		}
+	}
+	for i, buildpack := range desired {
+		if !inOrder[buildpack] {
+			changes = append(changes, buildpackChange{action: "add", buildpack: buildpack, index: i + 1})
+		}
+	}
+	return changes
+}
+
+// longestIncreasingSubsequence returns the indexes of the longest strictly
+// increasing subsequence of values.
+func longestIncreasingSubsequence(values []int) []int {
+	// tails[l] is the index of the smallest tail of an increasing
+	// subsequence of length l+1
+	tails := []int{}
+	prev := make([]int, len(values))
+	for i, v := range values {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if values[tails[mid]] < v {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
+	}
+
+	result := make([]int, len(tails))
+	if len(tails) == 0 {
+		return result
+	}
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = k
+	}
+	return result
+}
+
+// SetAppBuildpackList makes the app's buildpacks exactly the given ordered
+// list, with as few buildpacks:add and buildpacks:remove calls as possible.
+func (c *BaseClient) SetAppBuildpackList(appName string, buildpacks []BuildpackRef) error {
+	desired := make([]string, 0, len(buildpacks))
+	seen := map[string]bool{}
+	for _, ref := range buildpacks {
+		buildpack := ref.String()
+		if _, err := ParseBuildpackRef(buildpack); err != nil {
+			return err
+		}
+		if seen[buildpack] {
+			return invalidBuildpackRef(buildpack, "listed more than once")
+		}
+		seen[buildpack] = true
+		desired = append(desired, buildpack)
+	}
+
+	current, err := c.ListAppBuildpacks(appName)
+	if err != nil {
+		return err
+	}
+	if len(desired) == 0 {
+		if len(current) == 0 {
+			return nil
+		}
+		return c.ClearAppBuildpacks(appName)
+	}
+
+	for _, change := range buildpackListChanges(current, desired) {
+		if change.action == "remove" {
+			err = c.RemoveAppBuildpack(appName, change.buildpack)
+		} else {
+			err = c.AddAppBuildpackAtIndex(appName, change.buildpack, change.index)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s buildpack '%s': %w", change.action, change.buildpack, err)
+		}
+	}
+	return nil
+}
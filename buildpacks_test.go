@@ -0,0 +1,109 @@
+package dokku
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildpackRef(t *testing.T) {
+	for buildpack, expected := range map[string]BuildpackRef{
+		"heroku/nodejs": {Kind: BuildpackRefShortName, URL: "heroku/nodejs"},
+		"https://github.com/heroku/heroku-buildpack-go.git": {
+			Kind: BuildpackRefGit, URL: "https://github.com/heroku/heroku-buildpack-go.git",
+		},
+		"https://github.com/heroku/heroku-buildpack-go.git#v180": {
+			Kind: BuildpackRefGit, URL: "https://github.com/heroku/heroku-buildpack-go.git", Ref: "v180",
+		},
+		"git@github.com:heroku/heroku-buildpack-ruby.git#feature/x": {
+			Kind: BuildpackRefGit, URL: "git@github.com:heroku/heroku-buildpack-ruby.git", Ref: "feature/x",
+		},
+		"urn:cnb:registry:heroku/nodejs":       {Kind: BuildpackRefCNB, URL: "urn:cnb:registry:heroku/nodejs"},
+		"docker://heroku/buildpack-nodejs:3.0": {Kind: BuildpackRefCNB, URL: "docker://heroku/buildpack-nodejs:3.0"},
+		"heroku/nodejs@1.2.0":                  {Kind: BuildpackRefCNB, URL: "heroku/nodejs@1.2.0"},
+	} {
+		ref, err := ParseBuildpackRef(buildpack)
+		require.NoError(t, err, buildpack)
+		assert.Equal(t, expected, ref)
+		assert.Equal(t, buildpack, ref.String())
+	}
+
+	for _, buildpack := range []string{
+		"",
+		"nodejs",
+		"heroku/nodejs extra",
+		"ftp://example.com/buildpack.git",
+		"https:///buildpack.git",
+		"https://github.com",
+		"https://github.com/heroku/buildpack.git#",
+		"https://github.com/heroku/buildpack.git#a b",
+		"urn:cnb:",
+		"docker://",
+	} {
+		_, err := ParseBuildpackRef(buildpack)
+		assert.ErrorIs(t, err, InvalidBuildpackRefError, buildpack)
+	}
+}
+
+func TestBuildpackListChanges(t *testing.T) {
+	assert.Empty(t, buildpackListChanges([]string{"a", "b"}, []string{"a", "b"}))
+
+	assert.Equal(t, []buildpackChange{
+		{action: "add", buildpack: "c", index: 3},
+	}, buildpackListChanges([]string{"a", "b"}, []string{"a", "b", "c"}))
+
+	assert.Equal(t, []buildpackChange{
+		{action: "remove", buildpack: "x"},
+		{action: "add", buildpack: "c", index: 2},
+	}, buildpackListChanges([]string{"a", "x", "b"}, []string{"a", "c", "b"}))
+
+	// moving one buildpack to the front only touches that buildpack
+	assert.Equal(t, []buildpackChange{
+		{action: "remove", buildpack: "d"},
+		{action: "add", buildpack: "d", index: 1},
+	}, buildpackListChanges([]string{"a", "b", "c", "d"}, []string{"d", "a", "b", "c"}))
+}
+
+func TestSetAppBuildpackList(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("buildpacks:list api", "=====> api buildpack urls\nheroku/nodejs\nhttps://github.com/heroku/heroku-buildpack-python.git\nheroku/go")
+
+	refs := []BuildpackRef{}
+	for _, buildpack := range []string{"heroku/go", "heroku/nodejs", "https://github.com/heroku/heroku-buildpack-ruby.git#v1"} {
+		ref, err := ParseBuildpackRef(buildpack)
+		require.NoError(t, err)
+		refs = append(refs, ref)
+	}
+	require.NoError(t, client.SetAppBuildpackList("api", refs))
+	assert.Equal(t, []string{
+		"buildpacks:list api",
+		"buildpacks:remove api heroku/nodejs",
+		"buildpacks:remove api https://github.com/heroku/heroku-buildpack-python.git",
+		"buildpacks:add --index 2 api heroku/nodejs",
+		"buildpacks:add --index 3 api https://github.com/heroku/heroku-buildpack-ruby.git#v1",
+	}, fe.commands)
+
+	fe.commands = nil
+	require.NoError(t, client.SetAppBuildpackList("api", nil))
+	assert.Equal(t, []string{"buildpacks:list api", "buildpacks:clear api"}, fe.commands)
+
+	err := client.SetAppBuildpackList("api", []BuildpackRef{refs[0], refs[0]})
+	assert.ErrorIs(t, err, InvalidBuildpackRefError)
+}
+
+func TestListAppBuildpacksError(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.fail("buildpacks:list api", errors.New("exit status 1"))
+	fe.on("buildpacks:list api", " !     App api does not exist")
+
+	_, err := client.ListAppBuildpacks("api")
+	assert.Error(t, err)
+
+	client, fe = newFakeClient()
+	fe.on("buildpacks:list api", "=====> api buildpack urls")
+	buildpacks, err := client.ListAppBuildpacks("api")
+	require.NoError(t, err)
+	assert.Empty(t, buildpacks)
+}
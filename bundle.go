@@ -0,0 +1,407 @@
+package dokku
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type bundleManager interface {
+	ExportApp(appName string) (*AppBundle, error)
+	ImportApp(bundle *AppBundle, options *AppImportOptions) (*CommandOutputStream, error)
+}
+
+const AppBundleVersion = 1
+
+var (
+	UnsupportedBundleVersionError = errors.New("unsupported app bundle version")
+
+	dockerOptionPhases = []string{"build", "deploy", "run"}
+)
+
+// AppBundle holds everything needed to recreate an app on another dokku host.
+type AppBundle struct {
+	Version    int       `json:"version"`
+	AppName    string    `json:"app_name"`
+	ExportedAt time.Time `json:"exported_at"`
+
+	Config         map[string]string        `json:"config"`
+	DomainsEnabled bool                     `json:"domains_enabled"`
+	Domains        []string                 `json:"domains"`
+	Ports          []ProxyPortMapping       `json:"ports"`
+	Storage        []StorageBindMount       `json:"storage"`
+	Resources      *AppResourceReport       `json:"resources"`
+	Checks         *AppChecksReport         `json:"checks"`
+	Buildpacks     []string                 `json:"buildpacks"`
+	DockerOptions  *AppDockerOptionsReport  `json:"docker_options"`
+	Network        *AppNetworkReport        `json:"network"`
+	Nginx          *AppNginxReport          `json:"nginx"`
+	Registry       *AppDockerRegistryReport `json:"registry"`
+}
+
+type AppImportOptions struct {
+	// optional, defaults to the name of the exported app
+	AppName string
+	// redeploy the source app's registry image with git:from-image
+	DeployImage  bool
+	ImageOptions *GitImageOptions
+}
+
+func DecodeAppBundle(r io.Reader) (*AppBundle, error) {
+	var bundle AppBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode app bundle: %w", err)
+	}
+	if bundle.Version != AppBundleVersion {
+		return nil, fmt.Errorf("%w: %d", UnsupportedBundleVersionError, bundle.Version)
+	}
+	return &bundle, nil
+}
+
+func (b *AppBundle) Encode(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// RegistryImage returns the image the source app was last released as,
+// or an empty string if the bundle has no registry details.
+func (b *AppBundle) RegistryImage() string {
+	if b.Registry == nil || b.Registry.ComputedImageRepo == "" {
+		return ""
+	}
+	image := b.Registry.ComputedImageRepo
+	if b.Registry.ComputedServer != "" {
+		image = strings.TrimSuffix(b.Registry.ComputedServer, "/") + "/" + image
+	}
+	tag := b.Registry.TagVersion
+	if tag == "" {
+		tag = "latest"
+	}
+	return image + ":" + tag
+}
+
+func (u ResourceUnits) list() []*Resource {
+	var resources []*Resource
+	for _, res := range []*Resource{u.CPU, u.Memory, u.MemorySwap, u.Network, u.NetworkIngress, u.NetworkEgress, u.NvidiaGPU} {
+		if res != nil {
+			resources = append(resources, res)
+		}
+	}
+	return resources
+}
+
+// splitDockerOptions splits a docker-options report line into individual
+// options, keeping each flag together with its value.
+func splitDockerOptions(options string) []string {
+	var split []string
+	for _, field := range strings.Fields(options) {
+		if strings.HasPrefix(field, "-") || len(split) == 0 {
+			split = append(split, field)
+			continue
+		}
+		split[len(split)-1] += " " + field
+	}
+	return split
+}
+
+func isVolumeOption(option string) bool {
+	return strings.HasPrefix(option, "-v ") || strings.HasPrefix(option, "--volume ") ||
+		strings.HasPrefix(option, "--volume=")
+}
+
+func (c *BaseClient) ExportApp(appName string) (*AppBundle, error) {
+	bundle := &AppBundle{
+		Version:    AppBundleVersion,
+		AppName:    appName,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	// config:bundle keeps values exactly, unlike config:show
+	config, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export config: %w", err)
+	}
+	bundle.Config = withoutManagedConfigKeys(config)
+
+	domains, err := c.GetAppDomainsReport(appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export domains: %w", err)
+	}
+	bundle.DomainsEnabled = domains.AppEnabled
+	bundle.Domains = domains.AppDomains
+
+	if bundle.Ports, err = c.GetAppProxyPortMappings(appName); err != nil {
+		return nil, fmt.Errorf("failed to export ports: %w", err)
+	}
+	if bundle.Storage, err = c.ListAppStorage(appName); err != nil {
+		return nil, fmt.Errorf("failed to export storage: %w", err)
+	}
+	if bundle.Resources, err = c.GetAppResourceReport(appName); err != nil {
+		return nil, fmt.Errorf("failed to export resources: %w", err)
+	}
+	if bundle.Checks, err = c.GetAppDeployChecksReport(appName); err != nil {
+		return nil, fmt.Errorf("failed to export checks: %w", err)
+	}
+	if bundle.Buildpacks, err = c.ListAppBuildpacks(appName); err != nil {
+		return nil, fmt.Errorf("failed to export buildpacks: %w", err)
+	}
+	if bundle.DockerOptions, err = c.GetAppDockerOptionsReport(appName); err != nil {
+		return nil, fmt.Errorf("failed to export docker options: %w", err)
+	}
+	if bundle.Network, err = c.GetAppNetworkReport(appName); err != nil {
+		return nil, fmt.Errorf("failed to export network: %w", err)
+	}
+	if bundle.Nginx, err = c.GetAppNginxReport(appName); err != nil {
+		return nil, fmt.Errorf("failed to export nginx: %w", err)
+	}
+	if bundle.Registry, err = c.GetAppDockerRegistryReport(appName); err != nil {
+		return nil, fmt.Errorf("failed to export registry: %w", err)
+	}
+
+	return bundle, nil
+}
+
+// ImportApp creates an app from the bundle. If any setting fails to import,
+// the app is destroyed again so the import can be retried.
+func (c *BaseClient) ImportApp(bundle *AppBundle, options *AppImportOptions) (*CommandOutputStream, error) {
+	if bundle.Version != AppBundleVersion {
+		return nil, fmt.Errorf("%w: %d", UnsupportedBundleVersionError, bundle.Version)
+	}
+
+	appName := bundle.AppName
+	if options != nil && options.AppName != "" {
+		appName = options.AppName
+	}
+	deployImage := options != nil && options.DeployImage
+	image := bundle.RegistryImage()
+	if deployImage && image == "" {
+		return nil, errors.New("app bundle has no registry image to deploy")
+	}
+
+	if err := c.CreateApp(appName); err != nil {
+		return nil, err
+	}
+	if err := c.importAppSettings(appName, bundle); err != nil {
+		if destroyErr := c.DestroyApp(appName); destroyErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to destroy partly imported app: %w", destroyErr))
+		}
+		return nil, err
+	}
+
+	if !deployImage {
+		return nil, nil
+	}
+	return c.GitCreateFromImage(appName, image, options.ImageOptions)
+}
+
+func (c *BaseClient) importAppSettings(appName string, bundle *AppBundle) error {
+	steps := []struct {
+		name string
+		fn   func(appName string, bundle *AppBundle) error
+	}{
+		{"config", c.importAppConfig},
+		{"domains", c.importAppDomains},
+		{"ports", c.importAppPorts},
+		{"storage", c.importAppStorage},
+		{"resources", c.importAppResources},
+		{"checks", c.importAppChecks},
+		{"buildpacks", c.importAppBuildpacks},
+		{"docker options", c.importAppDockerOptions},
+		{"network", c.importAppNetwork},
+		{"nginx", c.importAppNginx},
+	}
+	for _, step := range steps {
+		if err := step.fn(appName, bundle); err != nil {
+			return fmt.Errorf("failed to import %s: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppConfig(appName string, bundle *AppBundle) error {
+	// bundles may come from older exports which kept the managed keys
+	config := withoutManagedConfigKeys(bundle.Config)
+	if len(config) == 0 {
+		return nil
+	}
+	return c.SetAppConfigValues(appName, config, false)
+}
+
+// withoutManagedConfigKeys leaves out the keys dokku sets itself, like the
+// ports, proxy and git rev of the app, which belong to the host.
+func withoutManagedConfigKeys(config map[string]string) map[string]string {
+	filtered := map[string]string{}
+	for key, value := range config {
+		if !matchesAnyPattern(key, dokkuManagedConfigKeys) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+func (c *BaseClient) importAppDomains(appName string, bundle *AppBundle) error {
+	if len(bundle.Domains) > 0 {
+		if err := c.SetAppDomains(appName, bundle.Domains); err != nil {
+			return err
+		}
+	}
+	if !bundle.DomainsEnabled {
+		return c.DisableAppDomains(appName)
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppPorts(appName string, bundle *AppBundle) error {
+	if len(bundle.Ports) == 0 {
+		return nil
+	}
+	return c.SetAppProxyPorts(appName, bundle.Ports)
+}
+
+func (c *BaseClient) importAppStorage(appName string, bundle *AppBundle) error {
+	for _, mount := range bundle.Storage {
+		if err := c.MountAppStorage(appName, mount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppResources(appName string, bundle *AppBundle) error {
+	if bundle.Resources == nil {
+		return nil
+	}
+	for _, res := range bundle.Resources.Defaults.Limits.list() {
+		if err := c.SetAppDefaultResourceLimit(appName, res.Type, res.Amount); err != nil {
+			return err
+		}
+	}
+	for _, res := range bundle.Resources.Defaults.Reservations.list() {
+		if err := c.SetAppResourceReservation(appName, res.Type, res.Amount); err != nil {
+			return err
+		}
+	}
+	for proc, settings := range bundle.Resources.Processes {
+		for _, res := range settings.Limits.list() {
+			if err := c.SetAppProcessResourceLimit(appName, proc, res.Type, res.Amount); err != nil {
+				return err
+			}
+		}
+		for _, res := range settings.Reservations.list() {
+			if err := c.SetAppProcessResourceReservation(appName, proc, res.Type, res.Amount); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppChecks(appName string, bundle *AppBundle) error {
+	if bundle.Checks == nil {
+		return nil
+	}
+	if bundle.Checks.AllDisabled {
+		if err := c.DisableAppDeployChecks(appName); err != nil {
+			return err
+		}
+	} else if len(bundle.Checks.DisabledProcesses) > 0 {
+		if err := c.DisableAppProcessesDeployChecks(appName, bundle.Checks.DisabledProcesses); err != nil {
+			return err
+		}
+	}
+	if bundle.Checks.AllSkipped {
+		return c.SetAppDeployChecksSkipped(appName)
+	} else if len(bundle.Checks.SkippedProcesses) > 0 {
+		return c.SetAppProcessesDeployChecksSkipped(appName, bundle.Checks.SkippedProcesses)
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppBuildpacks(appName string, bundle *AppBundle) error {
+	for i, buildpack := range bundle.Buildpacks {
+		if err := c.AddAppBuildpackAtIndex(appName, buildpack, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppDockerOptions(appName string, bundle *AppBundle) error {
+	if bundle.DockerOptions == nil {
+		return nil
+	}
+	phaseOptions := map[string]string{
+		"build":  bundle.DockerOptions.BuildOptions,
+		"deploy": bundle.DockerOptions.DeployOptions,
+		"run":    bundle.DockerOptions.RunOptions,
+	}
+	for _, phase := range dockerOptionPhases {
+		for _, option := range splitDockerOptions(phaseOptions[phase]) {
+			// volumes are recreated through the storage plugin
+			if isVolumeOption(option) {
+				continue
+			}
+			if err := c.AddAppPhaseDockerOption(appName, phase, option); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppNetwork(appName string, bundle *AppBundle) error {
+	if bundle.Network == nil {
+		return nil
+	}
+	props := map[NetworkProperty]string{
+		NetworkPropertyAttachPostCreate: bundle.Network.AttachPostCreate,
+		NetworkPropertyAttachPostDeploy: bundle.Network.AttachPostDeploy,
+		NetworkPropertyInitialNetwork:   bundle.Network.InitialNetwork,
+		NetworkPropertyTLD:              bundle.Network.TLD,
+	}
+	if bundle.Network.BindAllInterfaces {
+		props[NetworkPropertyBindAllInterfaces] = "true"
+	}
+	for property, value := range props {
+		if value == "" {
+			continue
+		}
+		if err := c.SetAppNetworkProperty(appName, property, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BaseClient) importAppNginx(appName string, bundle *AppBundle) error {
+	if bundle.Nginx == nil {
+		return nil
+	}
+	props := map[NginxProperty]string{
+		NginxPropertyBindAddressIPv4:  bundle.Nginx.BindAddressIPv4,
+		NginxPropertyBindAddressIPv6:  bundle.Nginx.BindAddressIPv6,
+		NginxPropertyAccessLogPath:    bundle.Nginx.AccessLogPath,
+		NginxPropertyAccessLogFormat:  bundle.Nginx.AccessLogFormat,
+		NginxPropertyErrorLogPath:     bundle.Nginx.ErrorLogPath,
+		NginxPropertyProxyReadTimeout: bundle.Nginx.ProxyReadTimeout,
+	}
+	if bundle.Nginx.ClientMaxBodySize > 0 {
+		props[NginxPropertyClientMaxBodySize] = fmt.Sprintf("%d", bundle.Nginx.ClientMaxBodySize)
+	}
+	if bundle.Nginx.HSTS != bundle.Nginx.GlobalHSTS {
+		props[NginxPropertyHSTSHeader] = fmt.Sprintf("%t", bundle.Nginx.HSTS)
+	}
+	for property, value := range props {
+		if value == "" {
+			continue
+		}
+		if err := c.SetAppNginxProperty(appName, property, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package dokku
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
 
 	"github.com/parkerdgabel/dokku-go/internal/reports"
@@ -32,6 +34,7 @@ type CertsReport map[string]*AppCertsReport
 
 const (
 	certsAddCmd      = "certs:add %s %s %s"
+	certsAddTarCmd   = "certs:add %s"
 	certsGenerateCmd = "certs:generate %s %s"
 	certsRemoveCmd   = "certs:remove %s"
 	certsReportCmd   = "certs:report %s"
@@ -103,3 +106,37 @@ func (c *BaseClient) GetCertsReport() (CertsReport, error) {
 
 	return report, nil
 }
+
+// addAppCertContents uploads a certificate and key as a tarball over stdin,
+// rather than referencing files already on the dokku host.
+func (c *BaseClient) addAppCertContents(appName string, crt string, key string) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := []struct {
+		name     string
+		contents string
+	}{
+		{"server.crt", crt},
+		{"server.key", key},
+	}
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name: file.name,
+			Mode: 0600,
+			Size: int64(len(file.contents)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(file.contents)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf(certsAddTarCmd, appName)
+	_, err := c.ExecWithInput(cmd, &buf)
+	return err
+}
@@ -2,10 +2,15 @@ package dokku
 
 import (
 	"io"
+	"strings"
+	"sync"
 )
 
 type BaseClient struct {
 	executor commandExecutor
+
+	// optional, records config before it is changed
+	configHistory ConfigHistoryStore
 }
 
 type commandExecutor interface {
@@ -13,10 +18,55 @@ type commandExecutor interface {
 	execStreaming(command string, input io.Reader) (*CommandOutputStream, error)
 }
 
+// argWhitespace is what dokku splits commands on, the default IFS, when they
+// aren't run through a shell.
+const argWhitespace = " \t\n"
+
+// argQuoter is implemented by executors which know how a command reaches
+// dokku. quoteArg returns the argument as it has to be written in a command
+// for dokku to receive it unchanged, or false if it can't be passed intact.
+type argQuoter interface {
+	quoteArg(arg string) (string, bool)
+}
+
+// quoteArg quotes an argument for the executor. Without a shell in between,
+// dokku splits commands on whitespace, so arguments containing it can't be
+// passed intact.
+func (c *BaseClient) quoteArg(arg string) (string, bool) {
+	if quoter, ok := c.executor.(argQuoter); ok {
+		return quoter.quoteArg(arg)
+	}
+	return arg, !strings.ContainsAny(arg, argWhitespace)
+}
+
 type CommandOutputStream struct {
 	Stdout io.Reader
 	Stderr io.Reader
 	Error  error
+
+	done chan struct{}
+}
+
+// Wait discards any unread output and blocks until the command has finished,
+// returning its error.
+func (s *CommandOutputStream) Wait() error {
+	var wg sync.WaitGroup
+	for _, r := range []io.Reader{s.Stdout, s.Stderr} {
+		if r == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+			_, _ = io.Copy(io.Discard, r)
+		}(r)
+	}
+	wg.Wait()
+
+	if s.done != nil {
+		<-s.done
+	}
+	return s.Error
 }
 
 func (c *BaseClient) Exec(command string) (string, error) {
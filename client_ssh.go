@@ -36,6 +36,9 @@ type SSHClientConfig struct {
 
 	// optional, defaults to using $HOME/.ssh/known_hosts
 	HostKeyCallback ssh.HostKeyCallback
+
+	// optional, records app config before every change made by the client
+	ConfigHistory ConfigHistoryStore
 }
 
 type sshExecutor struct {
@@ -125,6 +128,7 @@ func NewSSHClient(cfg *SSHClientConfig) (*SSHClient, error) {
 				conn: sshConn,
 				User: user,
 			},
+			configHistory: cfg.ConfigHistory,
 		},
 	}
 
@@ -139,6 +143,17 @@ func (c *SSHClient) Close() error {
 	return nil
 }
 
+// quoteArg quotes an argument for the ssh user. As the dokku user, the command
+// reaches dokku split on whitespace, without globbing or any unquoting, so
+// arguments are passed as they are and can't contain whitespace. Other users
+// run dokku through their shell, so arguments are single quoted.
+func (e *sshExecutor) quoteArg(arg string) (string, bool) {
+	if e.User == SshDokkuUser {
+		return arg, !strings.ContainsAny(arg, argWhitespace)
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'", true
+}
+
 func (e *sshExecutor) exec(cmd string, input io.Reader) (string, error) {
 	session, err := e.conn.NewSession()
 	if e.User != SshDokkuUser {
@@ -154,7 +169,7 @@ func (e *sshExecutor) exec(cmd string, input io.Reader) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		go io.Copy(stdin, input)
+		go copyStdin(stdin, input)
 	}
 
 	output, cmdErr := session.CombinedOutput(cmd)
@@ -189,16 +204,19 @@ func (e *sshExecutor) execStreaming(cmd string, input io.Reader) (*CommandOutput
 	if err != nil {
 		return nil, err
 	}
+	if e.User != SshDokkuUser {
+		cmd = fmt.Sprintf("dokku %s", cmd)
+	}
 
 	if input != nil {
 		stdin, err := session.StdinPipe()
 		if err != nil {
 			return nil, err
 		}
-		go io.Copy(stdin, input)
+		go copyStdin(stdin, input)
 	}
 
-	stream := &CommandOutputStream{}
+	stream := &CommandOutputStream{done: make(chan struct{})}
 
 	stream.Stdout, err = session.StdoutPipe()
 	if err != nil {
@@ -211,6 +229,7 @@ func (e *sshExecutor) execStreaming(cmd string, input io.Reader) (*CommandOutput
 	}
 
 	go func(stream *CommandOutputStream) {
+		defer close(stream.done)
 		cmdErr := session.Run(cmd)
 		if stream != nil {
 			stream.Error = cmdErr
@@ -227,6 +246,13 @@ func (e *sshExecutor) execStreaming(cmd string, input io.Reader) (*CommandOutput
 	return stream, nil
 }
 
+// copyStdin sends input to the command, closing stdin afterwards so the
+// command sees the end of its input.
+func copyStdin(stdin io.WriteCloser, input io.Reader) {
+	_, _ = io.Copy(stdin, input)
+	_ = stdin.Close()
+}
+
 func closeSession(session *ssh.Session) error {
 	// The session can be closed asynchronously at any time by the server,
 	// so it's always possible for correctly-written code to get an EOF error
@@ -1,10 +1,14 @@
 package dokku
 
 import (
+	"io"
 	"io/ioutil"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -33,3 +37,87 @@ func (s *checksManagerTestSuite) TestSSHClientExecStreamingError() {
 	time.Sleep(100 * time.Millisecond)
 	r.Error(stream.Error)
 }
+
+// fakeExecutor is a commandExecutor which answers commands from canned
+// output, so client logic can be tested without a dokku container.
+type fakeExecutor struct {
+	mu       sync.Mutex
+	outputs  map[string]string
+	errors   map[string]error
+	commands []string
+	inputs   map[string][]byte
+	// optional, answers commands whose output depends on earlier ones
+	handler func(cmd string) (string, error, bool)
+}
+
+func newFakeClient() (*BaseClient, *fakeExecutor) {
+	fe := &fakeExecutor{
+		outputs: map[string]string{},
+		errors:  map[string]error{},
+		inputs:  map[string][]byte{},
+	}
+	return &BaseClient{executor: fe}, fe
+}
+
+func normalizeCommand(cmd string) string {
+	return strings.Join(strings.Fields(cmd), " ")
+}
+
+func (fe *fakeExecutor) on(cmd string, output string) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.outputs[normalizeCommand(cmd)] = output
+}
+
+func (fe *fakeExecutor) fail(cmd string, err error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.errors[normalizeCommand(cmd)] = err
+}
+
+func (fe *fakeExecutor) run(cmd string, input io.Reader) (string, error) {
+	cmd = normalizeCommand(cmd)
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.commands = append(fe.commands, cmd)
+	if input != nil {
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return "", err
+		}
+		fe.inputs[cmd] = b
+	}
+	if fe.handler != nil {
+		if out, err, ok := fe.handler(cmd); ok {
+			return out, err
+		}
+	}
+	return fe.outputs[cmd], fe.errors[cmd]
+}
+
+func (fe *fakeExecutor) exec(cmd string, input io.Reader) (string, error) {
+	return fe.run(cmd, input)
+}
+
+func (fe *fakeExecutor) execStreaming(cmd string, input io.Reader) (*CommandOutputStream, error) {
+	out, err := fe.run(cmd, input)
+	return &CommandOutputStream{
+		Stdout: strings.NewReader(out),
+		Stderr: strings.NewReader(""),
+		Error:  err,
+	}, nil
+}
+
+func TestSSHExecutorQuoteArg(t *testing.T) {
+	dokkuUser := &sshExecutor{User: SshDokkuUser}
+	arg, ok := dokkuUser.quoteArg(`it's"$HOME"`)
+	assert.True(t, ok)
+	assert.Equal(t, `it's"$HOME"`, arg)
+	_, ok = dokkuUser.quoteArg("two words")
+	assert.False(t, ok)
+
+	rootUser := &sshExecutor{User: SshRootUser}
+	arg, ok = rootUser.quoteArg("two words, it's $HOME")
+	assert.True(t, ok)
+	assert.Equal(t, `'two words, it'\''s $HOME'`, arg)
+}
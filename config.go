@@ -3,6 +3,7 @@ package dokku
 import (
 	b64 "encoding/base64"
 	"fmt"
+	"io"
 	"strings"
 	"unicode"
 
@@ -40,6 +41,16 @@ type configManager interface {
 
 	GetAppConfigKeys(appName string) ([]string, error)
 	GetGlobalConfigKeys() ([]string, error)
+
+	StreamAppConfigBundle(appName string) (io.Reader, error)
+	GetAppConfigBundle(appName string) (map[string]string, error)
+
+	ImportDotenv(appName string, r io.Reader, options *DotenvImportOptions) (*ConfigDiff, error)
+	ExportDotenv(appName string, w io.Writer) error
+
+	BindConfig(appName string, cfg interface{}) error
+	ApplyConfig(appName string, cfg interface{}, restart bool) ([]string, error)
+	PatchAppConfig(appName string, desired map[string]string, options *ConfigPatchOptions) (*ConfigDiff, error)
 }
 
 type AppAppJsonReport struct {
@@ -79,12 +90,19 @@ const (
 	configUnsetCmd  = "config:unset %s %s %s"
 )
 
-func encodeKeyValPair(key, val string) (string, error) {
+func validateConfigKey(key string) error {
 	for _, r := range key {
 		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
-			return "", fmt.Errorf("invalid key '%s', contains %c", key, r)
+			return fmt.Errorf("invalid key '%s', contains %c", key, r)
 		}
 	}
+	return nil
+}
+
+func encodeKeyValPair(key, val string) (string, error) {
+	if err := validateConfigKey(key); err != nil {
+		return "", err
+	}
 	encodedVal := b64.StdEncoding.EncodeToString([]byte(val))
 	pair := fmt.Sprintf("%s='%s'", key, encodedVal)
 	return pair, nil
@@ -166,6 +184,9 @@ func (c *BaseClient) GetAppConfig(appName string) (map[string]string, error) {
 }
 
 func (c *BaseClient) ClearAppConfig(appName string, restart bool) error {
+	if err := c.recordConfigRevision(appName, "config:clear", nil); err != nil {
+		return err
+	}
 	restartFlag := getOptionalFlag("--no-restart", !restart)
 	cmd := fmt.Sprintf(configClearCmd, restartFlag, appName)
 	_, err := c.Exec(cmd)
@@ -184,7 +205,7 @@ func (c *BaseClient) ExportAppConfig(appName string, format ConfigExportFormat)
 	case ConfigExportFormatShell:
 		cmd = fmt.Sprintf(configExportCmd, "--format shell", appName)
 	case ConfigExportFormatTarBundle:
-		cmd = fmt.Sprintf(configBundleCmd, appName)
+		return "", BinaryConfigExportError
 	default:
 		return "", fmt.Errorf("unknown export format '%s'", format)
 	}
@@ -206,20 +227,15 @@ func (c *BaseClient) GetGlobalConfigValue(key string, quoted bool) (string, erro
 }
 
 func (c *BaseClient) SetAppConfigValue(appName string, key string, value string, restart bool) error {
-	restartFlag := getOptionalFlag("--no-restart", !restart)
-	pair, err := encodeKeyValPair(key, value)
-	if err != nil {
-		return err
-	}
-	cmd := fmt.Sprintf(configSetCmd, restartFlag, appName, pair)
-	_, err = c.Exec(cmd)
-	return err
+	return c.SetAppConfigValues(appName, map[string]string{key: value}, restart)
 }
 
 func (c *BaseClient) UnsetAppConfigValue(appName string, key string, restart bool) error {
-	restartFlag := getOptionalFlag("--no-restart", !restart)
-	cmd := fmt.Sprintf(configUnsetCmd, restartFlag, appName, key)
-	_, err := c.Exec(cmd)
+	keys := strings.Fields(key)
+	if err := c.recordConfigRevision(appName, "config:unset", keys); err != nil {
+		return err
+	}
+	_, err := c.Exec(configUnsetCommand(appName, keys, restart))
 	return err
 }
 
@@ -232,19 +248,35 @@ func (c *BaseClient) UnsetGlobalConfigValue(key string, restart bool) error {
 }
 
 func (c *BaseClient) SetAppConfigValues(appName string, config map[string]string, restart bool) error {
+	cmd, err := configSetCommand(appName, config, restart)
+	if err != nil {
+		return err
+	}
+	if err := c.recordConfigRevision(appName, "config:set", sortedKeys(config)); err != nil {
+		return err
+	}
+	_, err = c.Exec(cmd)
+	return err
+}
+
+// configSetCommand builds the config:set command, failing on invalid keys
+// before anything is run or recorded.
+func configSetCommand(appName string, config map[string]string, restart bool) (string, error) {
 	var pairs []string
 	for k, v := range config {
 		pair, err := encodeKeyValPair(k, v)
 		if err != nil {
-			return err
+			return "", err
 		}
 		pairs = append(pairs, pair)
 	}
 	restartFlag := getOptionalFlag("--no-restart", !restart)
-	strPairs := strings.Join(pairs, " ")
-	cmd := fmt.Sprintf(configSetCmd, restartFlag, appName, strPairs)
-	_, err := c.Exec(cmd)
-	return err
+	return fmt.Sprintf(configSetCmd, restartFlag, appName, strings.Join(pairs, " ")), nil
+}
+
+func configUnsetCommand(appName string, keys []string, restart bool) string {
+	restartFlag := getOptionalFlag("--no-restart", !restart)
+	return fmt.Sprintf(configUnsetCmd, restartFlag, appName, strings.Join(keys, " "))
 }
 
 func (c *BaseClient) UnsetAppConfigValues(appName string, keys []string, restart bool) error {
@@ -0,0 +1,345 @@
+package dokku
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config structs map their fields to config keys with env tags:
+//
+//	type Config struct {
+//		DatabaseURL *url.URL      `env:"DATABASE_URL,required"`
+//		Workers     int           `env:"WORKERS"`
+//		Timeout     time.Duration `env:"TIMEOUT,omitempty"`
+//		Origins     []string      `env:"ORIGINS"`
+//	}
+//
+// Slices are stored comma separated. Untagged struct fields are bound
+// recursively, other untagged fields are ignored. Required keys have to be
+// set when decoding, and can't be nil or empty when encoding.
+const (
+	configTagName = "env"
+
+	configTagRequired  = "required"
+	configTagOmitEmpty = "omitempty"
+
+	configSliceSeparator = ","
+)
+
+var (
+	InvalidConfigTargetError = errors.New("config target must be a non-nil pointer to a struct")
+
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type MissingConfigKeysError struct {
+	Keys []string
+}
+
+func (e *MissingConfigKeysError) Error() string {
+	return fmt.Sprintf("missing required config keys: %s", strings.Join(e.Keys, ", "))
+}
+
+type configField struct {
+	key       string
+	required  bool
+	omitEmpty bool
+	value     reflect.Value
+}
+
+func configFields(v reflect.Value) ([]configField, error) {
+	var fields []configField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// embedded structs of unexported types still promote their fields
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		tag, ok := sf.Tag.Lookup(configTagName)
+		if !ok {
+			if sf.Type.Kind() == reflect.Struct && sf.Type != urlType {
+				nested, err := configFields(v.Field(i))
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, nested...)
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		field := configField{key: parts[0], value: v.Field(i)}
+		if field.key == "" {
+			return nil, fmt.Errorf("field '%s' has an empty config key", sf.Name)
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case configTagRequired:
+				field.required = true
+			case configTagOmitEmpty:
+				field.omitEmpty = true
+			default:
+				return nil, fmt.Errorf("field '%s' has unknown config tag option '%s'", sf.Name, opt)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func configStructValue(cfg interface{}, settable bool) (reflect.Value, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, InvalidConfigTargetError
+		}
+		v = v.Elem()
+	} else if settable {
+		return reflect.Value{}, InvalidConfigTargetError
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, InvalidConfigTargetError
+	}
+	return v, nil
+}
+
+// DecodeConfig sets the tagged fields of the struct cfg points to from
+// config. Keys missing from config leave their fields untouched.
+func DecodeConfig(config map[string]string, cfg interface{}) error {
+	v, err := configStructValue(cfg, true)
+	if err != nil {
+		return err
+	}
+	fields, err := configFields(v)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, field := range fields {
+		raw, ok := config[field.key]
+		if !ok {
+			if field.required {
+				missing = append(missing, field.key)
+			}
+			continue
+		}
+		if err := decodeConfigValue(raw, field.value); err != nil {
+			return fmt.Errorf("invalid value for config key '%s': %w", field.key, err)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingConfigKeysError{Keys: missing}
+	}
+	return nil
+}
+
+// EncodeConfig converts the tagged fields of cfg to config values.
+func EncodeConfig(cfg interface{}) (map[string]string, error) {
+	v, err := configStructValue(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := configFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	var missing []string
+	for _, field := range fields {
+		if field.omitEmpty && !field.required && field.value.IsZero() {
+			continue
+		}
+		raw, err := encodeConfigValue(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for config key '%s': %w", field.key, err)
+		}
+		// false and 0 are values, only nil and empty values are missing
+		if field.required && raw == "" {
+			missing = append(missing, field.key)
+			continue
+		}
+		config[field.key] = raw
+	}
+	if len(missing) > 0 {
+		return nil, &MissingConfigKeysError{Keys: missing}
+	}
+	return config, nil
+}
+
+func decodeConfigValue(raw string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeConfigValue(raw, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.Type() == urlType {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), 0, 0)
+		if raw != "" {
+			for _, item := range strings.Split(raw, configSliceSeparator) {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := decodeConfigValue(strings.TrimSpace(item), elem); err != nil {
+					return err
+				}
+				slice = reflect.Append(slice, elem)
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func encodeConfigValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		return encodeConfigValue(v.Elem())
+	}
+
+	if v.Type() == urlType {
+		u := v.Interface().(url.URL)
+		return u.String(), nil
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			item, err := encodeConfigValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(item, configSliceSeparator) {
+				return "", fmt.Errorf("slice item '%s' contains '%s'", item, configSliceSeparator)
+			}
+			items[i] = item
+		}
+		return strings.Join(items, configSliceSeparator), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// BindConfig reads the app config into the struct cfg points to.
+func (c *BaseClient) BindConfig(appName string, cfg interface{}) error {
+	config, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return err
+	}
+	return DecodeConfig(config, cfg)
+}
+
+// ApplyConfig sets the config keys of cfg whose values differ from the
+// current app config, returning the sorted keys that were changed. Nothing
+// is set, and the app is not restarted, if all keys are up to date.
+func (c *BaseClient) ApplyConfig(appName string, cfg interface{}, restart bool) ([]string, error) {
+	desired, err := EncodeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// config:show trims and mangles multiline values, so compare against
+	// the exact values
+	current, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffConfig(current, desired, nil)
+	changes := diff.Added
+	for key, change := range diff.Changed {
+		changes[key] = change.New
+	}
+	changed := sortedKeys(changes)
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	if err := c.SetAppConfigValues(appName, changes, restart); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
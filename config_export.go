@@ -0,0 +1,173 @@
+package dokku
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	BinaryConfigExportError = errors.New("tar bundles can't be exported as a string, use StreamAppConfigBundle")
+)
+
+// StreamAppConfigBundle returns the app config as a tar archive with a file
+// per key. Reading returns the command's error once the archive is done.
+func (c *BaseClient) StreamAppConfigBundle(appName string) (io.Reader, error) {
+	cmd := fmt.Sprintf(configBundleCmd, appName)
+	stream, err := c.ExecStreaming(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &streamStdoutReader{stream: stream}, nil
+}
+
+func (c *BaseClient) GetAppConfigBundle(appName string) (map[string]string, error) {
+	r, err := c.StreamAppConfigBundle(appName)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfigBundle(r)
+	if err != nil {
+		return nil, err
+	}
+	// read to the end so the command's exit status is checked
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// streamStdoutReader reads the stdout of a command, and returns the
+// command's error instead of io.EOF if it failed.
+type streamStdoutReader struct {
+	stream *CommandOutputStream
+}
+
+func (r *streamStdoutReader) Read(p []byte) (int, error) {
+	n, err := r.stream.Stdout.Read(p)
+	if errors.Is(err, io.EOF) {
+		if waitErr := r.stream.Wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// ParseConfigBundle reads a config:bundle tar archive into a map.
+func ParseConfigBundle(r io.Reader) (map[string]string, error) {
+	config := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return config, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read config bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		value, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config bundle: %w", err)
+		}
+		config[path.Base(header.Name)] = string(value)
+	}
+}
+
+// ParseConfigExport parses the output of config:export in the eval
+// (export KEY='value') or shell (KEY='value' KEY2='value') formats.
+func ParseConfigExport(export string) (map[string]string, error) {
+	words, err := splitShellWords(export)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	for _, word := range words {
+		if word.raw == "export" {
+			continue
+		}
+		key, value, ok := strings.Cut(word.value, "=")
+		if !ok || !strings.HasPrefix(word.raw, key+"=") {
+			return nil, fmt.Errorf("invalid config export assignment '%s'", word.raw)
+		}
+		if err := validateConfigKey(key); err != nil {
+			return nil, err
+		}
+		config[key] = value
+	}
+	return config, nil
+}
+
+type shellWord struct {
+	raw   string
+	value string
+}
+
+// splitShellWords splits s into words the way a POSIX shell would, removing
+// quotes and escapes. Dokku quotes values with single quotes, and writes a
+// single quote in a value by closing the quotes, escaping it and reopening.
+func splitShellWords(s string) ([]shellWord, error) {
+	var words []shellWord
+	var value strings.Builder
+	start := -1
+
+	endWord := func(end int) {
+		if start >= 0 {
+			words = append(words, shellWord{raw: s[start:end], value: value.String()})
+		}
+		value.Reset()
+		start = -1
+	}
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' {
+			endWord(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+
+		switch ch {
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			value.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				value.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+		case '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			if s[i] != '\n' {
+				value.WriteByte(s[i])
+			}
+		default:
+			value.WriteByte(ch)
+		}
+	}
+	endWord(len(s))
+	return words, nil
+}
@@ -0,0 +1,83 @@
+package dokku
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfigBundle(t *testing.T, config map[string]string) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, key := range sortedKeys(config) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: key,
+			Mode: 0600,
+			Size: int64(len(config[key])),
+		}))
+		_, err := tw.Write([]byte(config[key]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.String()
+}
+
+func TestGetAppConfigBundle(t *testing.T) {
+	config := map[string]string{
+		"KEY":       "value with spaces",
+		"MULTILINE": "line one\nline two\n",
+		"BINARY":    "\x00\xff trailing  ",
+		"EMPTY":     "",
+	}
+	client, fe := newFakeClient()
+	fe.on("config:bundle api", testConfigBundle(t, config))
+
+	bundle, err := client.GetAppConfigBundle("api")
+	require.NoError(t, err)
+	assert.Equal(t, config, bundle)
+
+	_, err = client.ExportAppConfig("api", ConfigExportFormatTarBundle)
+	assert.ErrorIs(t, err, BinaryConfigExportError)
+}
+
+func TestStreamAppConfigBundleError(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.fail("config:bundle api", NotImplementedError)
+
+	r, err := client.StreamAppConfigBundle("api")
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.True(t, errors.Is(err, NotImplementedError))
+}
+
+func TestParseConfigExport(t *testing.T) {
+	eval := `export DATABASE_URL='postgres://db/app'
+export QUOTE='it'\''s'
+export MULTILINE='line one
+line two'
+export EMPTY=''`
+	config, err := ParseConfigExport(eval)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DATABASE_URL": "postgres://db/app",
+		"QUOTE":        "it's",
+		"MULTILINE":    "line one\nline two",
+		"EMPTY":        "",
+	}, config)
+
+	config, err = ParseConfigExport(`A='1 2' B="say \"hi\"" C=plain\ text`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "1 2", "B": `say "hi"`, "C": "plain text"}, config)
+
+	_, err = ParseConfigExport(`export A='unterminated`)
+	assert.Error(t, err)
+	_, err = ParseConfigExport(`export NOT_AN_ASSIGNMENT`)
+	assert.Error(t, err)
+	_, err = ParseConfigExport(`'A'=1`)
+	assert.Error(t, err)
+}
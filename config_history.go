@@ -0,0 +1,255 @@
+package dokku
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+type configHistoryManager interface {
+	SetConfigHistoryStore(store ConfigHistoryStore)
+	ListConfigRevisions(appName string) ([]*ConfigRevision, error)
+	RestoreConfigRevision(appName string, id string, options *ConfigPatchOptions) (*ConfigDiff, error)
+}
+
+// ConfigRevision is the config an app had before it was changed.
+type ConfigRevision struct {
+	ID        string            `json:"id"`
+	AppName   string            `json:"app_name"`
+	CreatedAt time.Time         `json:"created_at"`
+	Operation string            `json:"operation"`
+	Config    map[string]string `json:"config"`
+}
+
+// ConfigHistoryStore persists config revisions. Revisions are listed
+// oldest first.
+type ConfigHistoryStore interface {
+	SaveConfigRevision(revision *ConfigRevision) error
+	ListConfigRevisions(appName string) ([]*ConfigRevision, error)
+	GetConfigRevision(appName string, id string) (*ConfigRevision, error)
+}
+
+const (
+	configRevisionIDFormat = "20060102T150405.000000000Z"
+	configRevisionFileExt  = ".rev"
+	globalConfigHistoryDir = "_global"
+	secretboxNonceSize     = 24
+)
+
+var (
+	ConfigHistoryDisabledError   = errors.New("config history is not enabled")
+	ConfigRevisionNotFoundError  = errors.New("config revision does not exist")
+	InvalidConfigHistoryKeyError = errors.New("config history key must be 32 bytes")
+)
+
+func (c *BaseClient) SetConfigHistoryStore(store ConfigHistoryStore) {
+	c.configHistory = store
+}
+
+// recordConfigRevision saves the current config of the app before operation
+// changes keys, or all keys if keys is nil. It does nothing if no history
+// store is set, or if only keys dokku manages change, as those aren't
+// restored anyway.
+func (c *BaseClient) recordConfigRevision(appName string, operation string, keys []string) error {
+	if c.configHistory == nil {
+		return nil
+	}
+	if keys != nil {
+		managedOnly := true
+		for _, key := range keys {
+			managedOnly = managedOnly && matchesAnyPattern(key, dokkuManagedConfigKeys)
+		}
+		if managedOnly {
+			return nil
+		}
+	}
+	config, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot config: %w", err)
+	}
+	now := time.Now().UTC()
+	revision := &ConfigRevision{
+		ID:        now.Format(configRevisionIDFormat),
+		AppName:   appName,
+		CreatedAt: now,
+		Operation: operation,
+		Config:    config,
+	}
+	if err := c.configHistory.SaveConfigRevision(revision); err != nil {
+		return fmt.Errorf("failed to save config revision: %w", err)
+	}
+	return nil
+}
+
+func (c *BaseClient) ListConfigRevisions(appName string) ([]*ConfigRevision, error) {
+	if c.configHistory == nil {
+		return nil, ConfigHistoryDisabledError
+	}
+	return c.configHistory.ListConfigRevisions(appName)
+}
+
+// RestoreConfigRevision sets the app config back to a revision, leaving the
+// keys dokku manages alone. The restore is itself recorded in the history.
+func (c *BaseClient) RestoreConfigRevision(appName string, id string, options *ConfigPatchOptions) (*ConfigDiff, error) {
+	if c.configHistory == nil {
+		return nil, ConfigHistoryDisabledError
+	}
+	revision, err := c.configHistory.GetConfigRevision(appName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := map[string]string{}
+	for key, value := range revision.Config {
+		if !matchesAnyPattern(key, dokkuManagedConfigKeys) {
+			desired[key] = value
+		}
+	}
+	return c.PatchAppConfig(appName, desired, options)
+}
+
+// FileConfigHistoryStore keeps revisions in a directory per app, each
+// revision encrypted with secretbox.
+type FileConfigHistoryStore struct {
+	dir string
+	key *[32]byte
+}
+
+func GenerateConfigHistoryKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func NewFileConfigHistoryStore(dir string, key []byte) (*FileConfigHistoryStore, error) {
+	if len(key) != 32 {
+		return nil, InvalidConfigHistoryKeyError
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	store := &FileConfigHistoryStore{dir: dir, key: &[32]byte{}}
+	copy(store.key[:], key)
+	return store, nil
+}
+
+func (s *FileConfigHistoryStore) appDir(appName string) (string, error) {
+	if appName == "--global" {
+		return filepath.Join(s.dir, globalConfigHistoryDir), nil
+	}
+	if appName == "" || strings.ContainsAny(appName, `/\`) || strings.HasPrefix(appName, ".") {
+		return "", fmt.Errorf("invalid app name '%s'", appName)
+	}
+	return filepath.Join(s.dir, appName), nil
+}
+
+func (s *FileConfigHistoryStore) SaveConfigRevision(revision *ConfigRevision) error {
+	dir, err := s.appDir(revision.AppName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(revision)
+	if err != nil {
+		return err
+	}
+	var nonce [secretboxNonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return err
+	}
+	sealed := secretbox.Seal(nonce[:], plaintext, &nonce, s.key)
+
+	name := filepath.Join(dir, revision.ID+configRevisionFileExt)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(sealed); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileConfigHistoryStore) readRevision(name string) (*ConfigRevision, error) {
+	sealed, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < secretboxNonceSize {
+		return nil, fmt.Errorf("config revision '%s' is truncated", filepath.Base(name))
+	}
+	var nonce [secretboxNonceSize]byte
+	copy(nonce[:], sealed)
+	plaintext, ok := secretbox.Open(nil, sealed[secretboxNonceSize:], &nonce, s.key)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt config revision '%s'", filepath.Base(name))
+	}
+
+	var revision ConfigRevision
+	if err := json.Unmarshal(plaintext, &revision); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (s *FileConfigHistoryStore) ListConfigRevisions(appName string) ([]*ConfigRevision, error) {
+	dir, err := s.appDir(appName)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*ConfigRevision{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), configRevisionFileExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	// revision IDs are timestamps, so they sort chronologically
+	sort.Strings(names)
+
+	revisions := make([]*ConfigRevision, 0, len(names))
+	for _, name := range names {
+		revision, err := s.readRevision(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+func (s *FileConfigHistoryStore) GetConfigRevision(appName string, id string) (*ConfigRevision, error) {
+	dir, err := s.appDir(appName)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("%w: %s", ConfigRevisionNotFoundError, id)
+	}
+	revision, err := s.readRevision(filepath.Join(dir, id+configRevisionFileExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ConfigRevisionNotFoundError, id)
+	}
+	return revision, err
+}
@@ -0,0 +1,124 @@
+package dokku
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConfigHistoryStore(t *testing.T) (*FileConfigHistoryStore, string) {
+	dir := t.TempDir()
+	key, err := GenerateConfigHistoryKey()
+	require.NoError(t, err)
+	store, err := NewFileConfigHistoryStore(dir, key)
+	require.NoError(t, err)
+	return store, dir
+}
+
+func TestConfigHistoryRecordsChanges(t *testing.T) {
+	store, dir := newTestConfigHistoryStore(t)
+	client, fe := newFakeClient()
+	client.SetConfigHistoryStore(store)
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{"TOKEN": "before"}))
+
+	require.NoError(t, client.SetAppConfigValue("api", "TOKEN", "after", false))
+	require.NoError(t, client.UnsetAppConfigValues("api", []string{"TOKEN"}, false))
+	require.NoError(t, client.SetAppConfigValues("api", map[string]string{"DOKKU_GO_LOCK_OWNER": "me"}, false))
+	assert.Equal(t, []string{
+		"config:bundle api",
+		"config:set --no-restart --encoded api TOKEN='YWZ0ZXI='",
+		"config:bundle api",
+		"config:unset --no-restart api TOKEN",
+		"config:set --no-restart --encoded api DOKKU_GO_LOCK_OWNER='bWU='",
+	}, fe.commands)
+
+	revisions, err := client.ListConfigRevisions("api")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "config:set", revisions[0].Operation)
+	assert.Equal(t, "config:unset", revisions[1].Operation)
+	assert.Equal(t, map[string]string{"TOKEN": "before"}, revisions[0].Config)
+
+	files, err := filepath.Glob(filepath.Join(dir, "api", "*.rev"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "before")
+	assert.NotContains(t, string(raw), "TOKEN")
+
+	otherKey, err := GenerateConfigHistoryKey()
+	require.NoError(t, err)
+	otherStore, err := NewFileConfigHistoryStore(dir, otherKey)
+	require.NoError(t, err)
+	_, err = otherStore.ListConfigRevisions("api")
+	assert.ErrorContains(t, err, "failed to decrypt")
+}
+
+func TestConfigHistorySkipsInvalidKeys(t *testing.T) {
+	store, _ := newTestConfigHistoryStore(t)
+	client, fe := newFakeClient()
+	client.SetConfigHistoryStore(store)
+
+	assert.Error(t, client.SetAppConfigValue("api", "BAD KEY", "value", false))
+	assert.Error(t, client.SetAppConfigValues("api", map[string]string{"GOOD": "a", "BAD=KEY": "b"}, false))
+	assert.Empty(t, fe.commands)
+
+	revisions, err := client.ListConfigRevisions("api")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func TestRestoreConfigRevision(t *testing.T) {
+	store, _ := newTestConfigHistoryStore(t)
+	require.NoError(t, store.SaveConfigRevision(&ConfigRevision{
+		ID:      "20261019T120000.000000000Z",
+		AppName: "api",
+		Config:  map[string]string{"TOKEN": "before", "DOKKU_PROXY_PORT": "80"},
+	}))
+
+	client, fe := newFakeClient()
+	client.SetConfigHistoryStore(store)
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{"TOKEN": "after", "NEW": "value", "DOKKU_PROXY_PORT": "8080"}))
+
+	diff, err := client.RestoreConfigRevision("api", "20261019T120000.000000000Z", &ConfigPatchOptions{NoRestart: true})
+	require.NoError(t, err)
+	assert.Equal(t, "~TOKEN -NEW", diff.String())
+
+	// the patch is recorded as one revision
+	revisions, err := client.ListConfigRevisions("api")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "config:patch", revisions[1].Operation)
+	assert.Equal(t, map[string]string{"TOKEN": "after", "NEW": "value", "DOKKU_PROXY_PORT": "8080"}, revisions[1].Config)
+
+	_, err = client.RestoreConfigRevision("api", "missing", nil)
+	assert.ErrorIs(t, err, ConfigRevisionNotFoundError)
+
+	_, err = (&BaseClient{}).ListConfigRevisions("api")
+	assert.ErrorIs(t, err, ConfigHistoryDisabledError)
+}
+
+func TestRestoreGlobalConfigRevision(t *testing.T) {
+	store, _ := newTestConfigHistoryStore(t)
+	require.NoError(t, store.SaveConfigRevision(&ConfigRevision{
+		ID:      "20261019T120000.000000000Z",
+		AppName: "--global",
+		Config:  map[string]string{"CURL_TIMEOUT": "60"},
+	}))
+
+	client, fe := newFakeClient()
+	client.SetConfigHistoryStore(store)
+	fe.on("config:bundle --global", testConfigBundle(t, map[string]string{"CURL_TIMEOUT": "30"}))
+	fe.fail("ps:report --global", errors.New("app --global does not exist"))
+
+	diff, err := client.RestoreConfigRevision("--global", "20261019T120000.000000000Z", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "~CURL_TIMEOUT", diff.String())
+	assert.Equal(t, "config:set --no-restart --encoded --global CURL_TIMEOUT='NjA='", fe.commands[len(fe.commands)-1])
+	assert.NotContains(t, fe.commands, "ps:report --global")
+}
@@ -0,0 +1,170 @@
+package dokku
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ConfigDiff is the difference between two sets of config values.
+type ConfigDiff struct {
+	Added   map[string]string
+	Changed map[string]ConfigValueChange
+	Removed []string
+}
+
+type ConfigValueChange struct {
+	Old string
+	New string
+}
+
+type ConfigPatchOptions struct {
+	// keys matching these path.Match patterns are never removed, in addition
+	// to the keys dokku manages itself
+	Keep []string
+	// don't restart the app, even if its config changed
+	NoRestart   bool
+	Parallelism *ParallelismOptions
+	// optional, receives the output of the restart
+	Output io.Writer
+}
+
+// config keys set by dokku and its plugins, which a patch must not remove
+var dokkuManagedConfigKeys = []string{"DOKKU_*", "GIT_REV"}
+
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// String lists the changed keys without their values, so it can be logged.
+func (d *ConfigDiff) String() string {
+	var parts []string
+	for _, key := range sortedKeys(d.Added) {
+		parts = append(parts, "+"+key)
+	}
+	for _, key := range sortedKeys(d.Changed) {
+		parts = append(parts, "~"+key)
+	}
+	for _, key := range d.Removed {
+		parts = append(parts, "-"+key)
+	}
+	return strings.Join(parts, " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DiffConfig computes the changes needed to turn current into desired.
+// Current keys matching one of the keep patterns are not removed.
+func DiffConfig(current map[string]string, desired map[string]string, keep []string) *ConfigDiff {
+	diff := &ConfigDiff{
+		Added:   map[string]string{},
+		Changed: map[string]ConfigValueChange{},
+		Removed: []string{},
+	}
+	for key, value := range desired {
+		old, ok := current[key]
+		if !ok {
+			diff.Added[key] = value
+		} else if old != value {
+			diff.Changed[key] = ConfigValueChange{Old: old, New: value}
+		}
+	}
+	for key := range current {
+		if _, ok := desired[key]; ok || matchesAnyPattern(key, keep) {
+			continue
+		}
+		diff.Removed = append(diff.Removed, key)
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+func matchesAnyPattern(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// PatchAppConfig makes the app config match desired, restarting the app at
+// most once and only if something changed. The change is recorded as a
+// single config revision.
+func (c *BaseClient) PatchAppConfig(appName string, desired map[string]string, options *ConfigPatchOptions) (*ConfigDiff, error) {
+	if options == nil {
+		options = &ConfigPatchOptions{}
+	}
+
+	// config:show trims and mangles multiline values, which would show up
+	// as changes
+	current, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := append(append([]string{}, dokkuManagedConfigKeys...), options.Keep...)
+	diff := DiffConfig(current, desired, keep)
+	if diff.Empty() {
+		return diff, nil
+	}
+
+	set := map[string]string{}
+	for key, value := range diff.Added {
+		set[key] = value
+	}
+	for key, change := range diff.Changed {
+		set[key] = change.New
+	}
+	setCmd, err := configSetCommand(appName, set, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set config: %w", err)
+	}
+	// the set and unset are one change, so they're recorded as one revision
+	changed := append(sortedKeys(set), diff.Removed...)
+	if err := c.recordConfigRevision(appName, "config:patch", changed); err != nil {
+		return nil, err
+	}
+	if len(set) > 0 {
+		if _, err := c.Exec(setCmd); err != nil {
+			return nil, fmt.Errorf("failed to set config: %w", err)
+		}
+	}
+	if len(diff.Removed) > 0 {
+		if _, err := c.Exec(configUnsetCommand(appName, diff.Removed, false)); err != nil {
+			return nil, fmt.Errorf("failed to unset config: %w", err)
+		}
+	}
+
+	// global config has no app to restart, apps pick it up on their next
+	// restart
+	if options.NoRestart || appName == "--global" {
+		return diff, nil
+	}
+
+	// like config:set, only restart apps that have been deployed
+	report, err := c.GetAppProcessReport(appName)
+	if err != nil {
+		return diff, err
+	}
+	if !report.Deployed {
+		return diff, nil
+	}
+	stream, err := c.RestartApp(appName, options.Parallelism)
+	if err != nil {
+		return diff, fmt.Errorf("failed to restart app: %w", err)
+	}
+	if err := copyStreamOutput(stream, options.Output); err != nil {
+		return diff, fmt.Errorf("failed to restart app: %w", err)
+	}
+	return diff, nil
+}
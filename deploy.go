@@ -0,0 +1,300 @@
+package dokku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type deployManager interface {
+	BlueGreenDeploy(ctx context.Context, appName string, options *BlueGreenOptions) (*BlueGreenDeployment, error)
+}
+
+type BlueGreenOptions struct {
+	// the repository and ref deployed to the staging app with git:sync
+	Repository string
+	GitRef     string
+
+	// optional, defaults to the app name suffixed with -blue or -green,
+	// whichever the live app is not using
+	StagingAppName string
+
+	// how long the previous app is kept around after the swap so the
+	// deployment can be rolled back
+	RollbackWindow time.Duration
+
+	// optional, after the domains are swapped the new app is watched for
+	// this long, and rolled back if it stops running or CanaryCheck fails
+	CanaryPeriod time.Duration
+	CanaryCheck  func(appName string) error
+
+	// optional, defaults to 5 seconds
+	PollInterval time.Duration
+
+	// optional, receives the build output of the staging app
+	Output io.Writer
+}
+
+type BlueGreenDeployment struct {
+	PreviousApp   string
+	CurrentApp    string
+	Domains       []string
+	SwappedAt     time.Time
+	RollbackUntil time.Time
+
+	client     *BaseClient
+	mu         sync.Mutex
+	rolledBack bool
+	finalized  bool
+}
+
+const (
+	blueAppSuffix  = "-blue"
+	greenAppSuffix = "-green"
+
+	defaultDeployPollInterval = 5 * time.Second
+)
+
+var (
+	DeploymentRolledBackError = errors.New("deployment has been rolled back")
+	DeploymentFinalizedError  = errors.New("deployment has been finalized")
+	RollbackWindowClosedError = errors.New("rollback window has passed")
+)
+
+func stagingAppName(appName string) string {
+	if strings.HasSuffix(appName, greenAppSuffix) {
+		return strings.TrimSuffix(appName, greenAppSuffix) + blueAppSuffix
+	}
+	return strings.TrimSuffix(appName, blueAppSuffix) + greenAppSuffix
+}
+
+func copyStreamOutput(stream *CommandOutputStream, w io.Writer) error {
+	if w != nil {
+		var wg sync.WaitGroup
+		for _, r := range []io.Reader{stream.Stdout, stream.Stderr} {
+			if r == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(r io.Reader) {
+				defer wg.Done()
+				_, _ = io.Copy(w, r)
+			}(r)
+		}
+		wg.Wait()
+	}
+	return stream.Wait()
+}
+
+func (c *BaseClient) BlueGreenDeploy(ctx context.Context, appName string, options *BlueGreenOptions) (*BlueGreenDeployment, error) {
+	if options == nil || options.Repository == "" {
+		return nil, errors.New("a repository to deploy is required")
+	}
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = defaultDeployPollInterval
+	}
+	staging := options.StagingAppName
+	if staging == "" {
+		staging = stagingAppName(appName)
+	}
+
+	d := &BlueGreenDeployment{
+		PreviousApp: appName,
+		CurrentApp:  staging,
+		client:      c,
+	}
+
+	if err := c.CloneApp(appName, staging, &AppManagementOptions{SkipDeploy: true}); err != nil {
+		return nil, fmt.Errorf("failed to clone app: %w", err)
+	}
+
+	// the staging app must not claim the live domains until it is healthy
+	if err := c.ClearAppDomains(staging); err != nil {
+		return nil, d.abort(fmt.Errorf("failed to clear staging domains: %w", err))
+	}
+
+	stream, err := c.GitSyncAppRepo(staging, options.Repository, &GitSyncOptions{
+		Build:  true,
+		GitRef: options.GitRef,
+	})
+	if err != nil {
+		return nil, d.abort(fmt.Errorf("failed to deploy staging app: %w", err))
+	}
+	if err := copyStreamOutput(stream, options.Output); err != nil {
+		return nil, d.abort(fmt.Errorf("failed to deploy staging app: %w", err))
+	}
+
+	waitOptions := &WaitOptions{InitialInterval: interval, MaxInterval: interval}
+	if _, err := c.WaitForAppRunning(ctx, staging, waitOptions); err != nil {
+		return nil, d.abort(fmt.Errorf("staging app is not healthy: %w", err))
+	}
+
+	if err := d.swap(); err != nil {
+		return nil, d.rollbackAfter(err)
+	}
+
+	if options.CanaryPeriod > 0 {
+		if err := d.watchCanary(ctx, options, interval); err != nil {
+			return nil, d.rollbackAfter(err)
+		}
+	}
+
+	d.SwappedAt = time.Now()
+	d.RollbackUntil = d.SwappedAt.Add(options.RollbackWindow)
+	return d, nil
+}
+
+func (d *BlueGreenDeployment) swap() error {
+	c := d.client
+
+	domains, err := c.GetAppDomainsReport(d.PreviousApp)
+	if err != nil {
+		return fmt.Errorf("failed to get domains: %w", err)
+	}
+	d.Domains = domains.AppDomains
+
+	certs, err := c.GetAppCertsReport(d.PreviousApp)
+	if err != nil {
+		return fmt.Errorf("failed to get certs: %w", err)
+	}
+	if certs.Enabled {
+		crt, err := c.ShowAppCertCRT(d.PreviousApp)
+		if err != nil {
+			return fmt.Errorf("failed to read certificate: %w", err)
+		}
+		key, err := c.ShowAppCertKey(d.PreviousApp)
+		if err != nil {
+			return fmt.Errorf("failed to read certificate key: %w", err)
+		}
+		if err := c.addAppCertContents(d.CurrentApp, crt, key); err != nil {
+			return fmt.Errorf("failed to copy certificate: %w", err)
+		}
+	}
+
+	return d.moveDomains(d.PreviousApp, d.CurrentApp)
+}
+
+// moveDomains adds the domains to the new app before removing them from the
+// old one, so there is no moment when they aren't routed anywhere.
+func (d *BlueGreenDeployment) moveDomains(from string, to string) error {
+	if len(d.Domains) == 0 {
+		return nil
+	}
+	if err := d.client.SetAppDomains(to, d.Domains); err != nil {
+		return fmt.Errorf("failed to set domains of '%s': %w", to, err)
+	}
+	if err := d.client.ClearAppDomains(from); err != nil {
+		return fmt.Errorf("failed to clear domains of '%s': %w", from, err)
+	}
+	return nil
+}
+
+func (d *BlueGreenDeployment) watchCanary(ctx context.Context, options *BlueGreenOptions, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, options.CanaryPeriod)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := d.client.GetAppProcessReport(d.CurrentApp)
+		if err != nil {
+			return fmt.Errorf("canary check failed: %w", err)
+		}
+		if !report.Running {
+			return errors.New("canary check failed: app is not running")
+		}
+		if options.CanaryCheck != nil {
+			if err := options.CanaryCheck(d.CurrentApp); err != nil {
+				return fmt.Errorf("canary check failed: %w", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// abort destroys the staging app before any domains have been moved.
+func (d *BlueGreenDeployment) abort(cause error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rolledBack = true
+	if err := d.client.DestroyApp(d.CurrentApp); err != nil {
+		return fmt.Errorf("%w (cleanup of '%s' failed: %s)", cause, d.CurrentApp, err.Error())
+	}
+	return cause
+}
+
+// rollbackAfter rolls back a deployment which failed before it was returned,
+// when there is no rollback window yet.
+func (d *BlueGreenDeployment) rollbackAfter(cause error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.rollback(); err != nil {
+		return fmt.Errorf("%w (rollback failed: %s)", cause, err.Error())
+	}
+	return cause
+}
+
+// Rollback moves the domains back to the previous app and destroys the new
+// one. It fails with RollbackWindowClosedError once RollbackUntil has passed.
+func (d *BlueGreenDeployment) Rollback() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rolledBack {
+		return DeploymentRolledBackError
+	}
+	if d.finalized {
+		return DeploymentFinalizedError
+	}
+	if time.Now().After(d.RollbackUntil) {
+		return RollbackWindowClosedError
+	}
+	return d.rollback()
+}
+
+func (d *BlueGreenDeployment) rollback() error {
+	if err := d.moveDomains(d.CurrentApp, d.PreviousApp); err != nil {
+		return err
+	}
+	if err := d.client.DestroyApp(d.CurrentApp); err != nil {
+		return err
+	}
+	d.rolledBack = true
+	return nil
+}
+
+// Finalize waits for the rollback window to pass, then destroys the previous app.
+func (d *BlueGreenDeployment) Finalize(ctx context.Context) error {
+	timer := time.NewTimer(time.Until(d.RollbackUntil))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rolledBack {
+		return DeploymentRolledBackError
+	}
+	if d.finalized {
+		return DeploymentFinalizedError
+	}
+	if err := d.client.DestroyApp(d.PreviousApp); err != nil {
+		return err
+	}
+	d.finalized = true
+	return nil
+}
@@ -0,0 +1,215 @@
+package dokku
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+const (
+	GitArchiveTypeTar   = "tar"
+	GitArchiveTypeTarGz = "tar.gz"
+	GitArchiveTypeZip   = "zip"
+
+	// read the archive from stdin
+	gitArchiveStdin = "--"
+)
+
+// files with gitignore style patterns of the files left out of deploy
+// tarballs, in order of precedence
+var deployIgnoreFiles = []string{".gitignore", ".dokkuignore"}
+
+// GitDeployArchive deploys the app from a tar, tar.gz or zip archive sent
+// over the client's connection, so the dokku host doesn't need to fetch it.
+// The archive type defaults to tar.
+func (c *BaseClient) GitDeployArchive(appName string, archive io.Reader, opt *GitArchiveOptions) (*CommandOutputStream, error) {
+	var authorDetails string
+	archiveType := GitArchiveTypeTar
+	if opt != nil {
+		if opt.AuthorDetails != nil {
+			authorDetails = opt.AuthorDetails.String()
+		}
+		if opt.ArchiveType != "" {
+			archiveType = opt.ArchiveType
+		}
+	}
+	cmd := fmt.Sprintf(gitFromArchiveCmd, archiveType, appName, gitArchiveStdin, authorDetails)
+	return c.ExecWithInputStreaming(cmd, archive)
+}
+
+// DeployTarball returns a tar archive of fsys for GitDeployArchive, written
+// as it is read. Errors are returned by Read.
+func DeployTarball(fsys fs.FS) io.Reader {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(WriteDeployTarball(w, fsys))
+	}()
+	return r
+}
+
+// WriteDeployTarball writes a tar archive of fsys, leaving out the .git
+// directory and files matched by .gitignore and .dokkuignore files. Like
+// with git, files in an ignored directory can't be included again. Files
+// other than regular files and directories, like symlinks, are left out.
+func WriteDeployTarball(w io.Writer, fsys fs.FS) error {
+	tw := tar.NewWriter(w)
+	if err := writeTarDir(tw, fsys, ".", nil); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeTarDir(tw *tar.Writer, fsys fs.FS, dir string, rules []ignoreRule) error {
+	for _, name := range deployIgnoreFiles {
+		content, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err == nil {
+			rules = append(rules, parseIgnoreRules(dir, string(content))...)
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Name())
+		if entry.Name() == ".git" || isIgnored(rules, entryPath, entry.IsDir()) {
+			continue
+		}
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entryPath
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if err := writeTarDir(tw, fsys, entryPath, rules); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := fsys.Open(entryPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to archive '%s': %w", entryPath, err)
+		}
+	}
+	return nil
+}
+
+// ignoreRule is a gitignore pattern.
+type ignoreRule struct {
+	// the directory of the ignore file
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	// patterns with a slash are relative to base, others match names in
+	// any directory below it
+	anchored bool
+}
+
+func parseIgnoreRules(base string, content string) []ignoreRule {
+	if base == "." {
+		base = ""
+	}
+	rules := []ignoreRule{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		rule.segments = strings.Split(line, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (r *ignoreRule) matches(name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		rel, ok := strings.CutPrefix(name, r.base+"/")
+		if !ok {
+			return false
+		}
+		name = rel
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(name))
+		return ok
+	}
+	return matchIgnoreSegments(r.segments, strings.Split(name, "/"))
+}
+
+func matchIgnoreSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// ** matches any number of directories, including none
+			for i := 0; i <= len(name); i++ {
+				if matchIgnoreSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// isIgnored applies the rules in order, so later rules override earlier
+// ones.
+func isIgnored(rules []ignoreRule, name string, isDir bool) bool {
+	ignored := false
+	for i := range rules {
+		if rules[i].matches(name, isDir) {
+			ignored = !rules[i].negate
+		}
+	}
+	return ignored
+}
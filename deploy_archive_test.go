@@ -0,0 +1,86 @@
+package dokku
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarEntries(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	entries := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[header.Name] = string(content)
+	}
+}
+
+func TestWriteDeployTarball(t *testing.T) {
+	files := fstest.MapFS{
+		".gitignore":            {Data: []byte("# build output\n*.log\n/dist/\nnode_modules/\n!keep.log\n")},
+		".dokkuignore":          {Data: []byte("docs/**/*.md\n/secrets.env\n")},
+		"Procfile":              {Data: []byte("web: npm start\n")},
+		"app.log":               {Data: []byte("ignored")},
+		"keep.log":              {Data: []byte("kept")},
+		"secrets.env":           {Data: []byte("ignored")},
+		"dist/bundle.js":        {Data: []byte("ignored")},
+		"src/dist/main.js":      {Data: []byte("only the top dist is ignored")},
+		"src/node_modules/x.js": {Data: []byte("ignored")},
+		"docs/a/b/guide.md":     {Data: []byte("ignored")},
+		"docs/a/diagram.png":    {Data: []byte("png")},
+		"lib/.gitignore":        {Data: []byte("*.tmp\n!important.tmp\n")},
+		"lib/cache.tmp":         {Data: []byte("ignored")},
+		"lib/important.tmp":     {Data: []byte("kept")},
+		".git/config":           {Data: []byte("ignored")},
+		"bin/run":               {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDeployTarball(&buf, files))
+	entries := tarEntries(t, &buf)
+
+	for _, name := range []string{
+		".gitignore", ".dokkuignore", "Procfile", "keep.log", "src/dist/main.js",
+		"docs/a/diagram.png", "lib/important.tmp", "bin/run", "lib/",
+	} {
+		assert.Contains(t, entries, name)
+	}
+	for _, name := range []string{
+		"app.log", "secrets.env", "dist/", "dist/bundle.js", "src/node_modules/x.js",
+		"docs/a/b/guide.md", "lib/cache.tmp", ".git/config",
+	} {
+		assert.NotContains(t, entries, name)
+	}
+	assert.Equal(t, "web: npm start\n", entries["Procfile"])
+}
+
+func TestGitDeployArchive(t *testing.T) {
+	client, fe := newFakeClient()
+	files := fstest.MapFS{"Procfile": {Data: []byte("web: ./run\n")}}
+
+	stream, err := client.GitDeployArchive("api", DeployTarball(files), &GitArchiveOptions{
+		AuthorDetails: &GitAuthorDetails{Username: "deployer", Email: "deployer@example.com"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, stream.Wait())
+
+	cmd := `git:from-archive --archive-type tar api -- "deployer" "deployer@example.com"`
+	assert.Equal(t, []string{cmd}, fe.commands)
+	assert.Equal(t, map[string]string{"Procfile": "web: ./run\n"}, tarEntries(t, bytes.NewReader(fe.inputs[cmd])))
+
+	_, err = client.GitDeployArchive("api", bytes.NewReader(nil), &GitArchiveOptions{ArchiveType: GitArchiveTypeZip})
+	require.NoError(t, err)
+	assert.Equal(t, "git:from-archive --archive-type zip api --", fe.commands[1])
+}
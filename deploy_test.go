@@ -0,0 +1,121 @@
+package dokku
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRunningProcessReport = `=====> api-green ps information
+       Deployed:                      true
+       Running:                       true`
+	testDomainsReport = `=====> api domains information
+       Domains app enabled:           true
+       Domains app vhosts:            api.example.com`
+)
+
+func TestStagingAppName(t *testing.T) {
+	assert.Equal(t, "api-green", stagingAppName("api"))
+	assert.Equal(t, "api-blue", stagingAppName("api-green"))
+	assert.Equal(t, "api-green", stagingAppName("api-blue"))
+}
+
+func TestBlueGreenDeploy(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report api-green", testRunningProcessReport)
+	fe.on("domains:report api", testDomainsReport)
+	fe.on("certs:report api", "=====> api ssl info\n       Ssl enabled:                   true")
+	fe.on("certs:show api crt", "CERT")
+	fe.on("certs:show api key", "KEY")
+
+	d, err := client.BlueGreenDeploy(context.Background(), "api", &BlueGreenOptions{
+		Repository:     "https://example.com/api.git",
+		GitRef:         "v2",
+		RollbackWindow: time.Millisecond,
+		PollInterval:   time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "api-green", d.CurrentApp)
+
+	assert.Equal(t, []string{
+		"apps:clone api api-green --skip-deploy",
+		"domains:clear api-green",
+		"git:sync --build api-green https://example.com/api.git v2",
+		"ps:report api-green",
+		"domains:report api",
+		"certs:report api",
+		"certs:show api crt",
+		"certs:show api key",
+		"certs:add api-green",
+		"domains:set api-green api.example.com",
+		"domains:clear api",
+	}, fe.commands)
+	assert.NotEmpty(t, fe.inputs["certs:add api-green"])
+
+	require.NoError(t, d.Finalize(context.Background()))
+	assert.Equal(t, "apps:destroy --force api", fe.commands[len(fe.commands)-1])
+	assert.ErrorIs(t, d.Rollback(), DeploymentFinalizedError)
+}
+
+func TestBlueGreenDeployRollback(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report api-green", testRunningProcessReport)
+	fe.on("domains:report api", testDomainsReport)
+	fe.on("certs:report api", "=====> api ssl info\n       Ssl enabled:                   false")
+
+	canaryErr := errors.New("error rate too high")
+	_, err := client.BlueGreenDeploy(context.Background(), "api", &BlueGreenOptions{
+		Repository:   "https://example.com/api.git",
+		CanaryPeriod: time.Second,
+		PollInterval: time.Millisecond,
+		CanaryCheck: func(appName string) error {
+			return canaryErr
+		},
+	})
+	require.ErrorIs(t, err, canaryErr)
+
+	assert.Equal(t, []string{
+		"domains:set api api.example.com",
+		"domains:clear api-green",
+		"apps:destroy --force api-green",
+	}, fe.commands[len(fe.commands)-3:])
+}
+
+func TestBlueGreenDeploymentRollbackWindow(t *testing.T) {
+	client, fe := newFakeClient()
+	d := &BlueGreenDeployment{
+		PreviousApp:   "api",
+		CurrentApp:    "api-green",
+		Domains:       []string{"api.example.com"},
+		RollbackUntil: time.Now().Add(-time.Second),
+		client:        client,
+	}
+	assert.ErrorIs(t, d.Rollback(), RollbackWindowClosedError)
+	assert.Empty(t, fe.commands)
+
+	d.RollbackUntil = time.Now().Add(time.Hour)
+	require.NoError(t, d.Rollback())
+	assert.Equal(t, []string{
+		"domains:set api api.example.com",
+		"domains:clear api-green",
+		"apps:destroy --force api-green",
+	}, fe.commands)
+	assert.ErrorIs(t, d.Rollback(), DeploymentRolledBackError)
+}
+
+func TestBlueGreenDeployFailedBuild(t *testing.T) {
+	client, fe := newFakeClient()
+	buildErr := errors.New("build failed")
+	fe.fail("git:sync --build api-green https://example.com/api.git", buildErr)
+
+	_, err := client.BlueGreenDeploy(context.Background(), "api", &BlueGreenOptions{
+		Repository: "https://example.com/api.git",
+	})
+	require.ErrorIs(t, err, buildErr)
+	assert.Equal(t, "apps:destroy --force api-green", fe.commands[len(fe.commands)-1])
+}
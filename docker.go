@@ -18,6 +18,11 @@ type dockerManager interface {
 	ClearAppPhaseDockerOptions(appName string, phase string) error
 	RemoveAppPhaseDockerOption(appName string, phase string, option string) error
 
+	GetAppBuildArgs(appName string) (map[string]string, error)
+	SetAppBuildArgs(appName string, args map[string]string) error
+	SetAppDockerfileCacheBuild(appName string, enabled bool) error
+	CheckAppBuildArgs(appName string) error
+
 	LoginDockerRegistry(server string, username string, password string) error
 	GetAppDockerRegistryReport(appName string) (*AppDockerRegistryReport, error)
 	GetDockerRegistryReport() (DockerRegistryReport, error)
@@ -62,6 +67,7 @@ const (
 	DockerRegistryPropertyServer        = DockerRegistryProperty("server")
 	DockerRegistryPropertyImageRepo     = DockerRegistryProperty("image-repo")
 	DockerRegistryPropertyPushOnRelease = DockerRegistryProperty("push-on-release")
+	DockerRegistryPropertyTagVersion    = DockerRegistryProperty("tag-version")
 )
 
 const (
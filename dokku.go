@@ -4,23 +4,31 @@ type Client interface {
 	commandExecutor
 
 	appManager
+	bundleManager
 	builderManager
 	certsManager
 	checksManager
+	configHistoryManager
 	configManager
 	cronManager
+	deployManager
 	dockerManager
 	domainsManager
 	gitManager
 	letsEncryptManager
+	lockManager
 	logsManager
 	networkManager
 	nginxManager
 	pluginManager
 	processManager
 	proxyManager
+	releaseManager
 	resourceManager
 	schedulerManager
+	secretsManager
+	snapshotManager
 	sshKeysManager
 	storageManager
+	waitManager
 }
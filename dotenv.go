@@ -0,0 +1,301 @@
+package dokku
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type DotenvImportOptions struct {
+	// unset app config keys which are not in the file, except the keys
+	// dokku manages itself
+	Prune bool
+	// don't restart the app, even if its config changed
+	NoRestart bool
+	// optional, used to interpolate variables not defined in the file,
+	// e.g. os.LookupEnv. Undefined variables are empty otherwise.
+	Lookup func(key string) (string, bool)
+}
+
+// ImportDotenv sets the app config from a .env file with PatchAppConfig,
+// restarting the app at most once. Values are only set if they changed, and
+// keys dokku manages itself are ignored.
+func (c *BaseClient) ImportDotenv(appName string, r io.Reader, options *DotenvImportOptions) (*ConfigDiff, error) {
+	if options == nil {
+		options = &DotenvImportOptions{}
+	}
+	values, err := ParseDotenv(r, options.Lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	// keys dokku manages describe the app the file was exported from
+	values = withoutManagedConfigKeys(values)
+
+	patchOptions := &ConfigPatchOptions{NoRestart: options.NoRestart}
+	if !options.Prune {
+		// config keys can't contain '/', so this keeps every key
+		patchOptions.Keep = []string{"*"}
+	}
+	return c.PatchAppConfig(appName, values, patchOptions)
+}
+
+// ExportDotenv writes the app config as a .env file, which ParseDotenv
+// reads back unchanged. Keys dokku manages itself are left out.
+func (c *BaseClient) ExportDotenv(appName string, w io.Writer) error {
+	config, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return err
+	}
+	config = withoutManagedConfigKeys(config)
+	for _, key := range sortedKeys(config) {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", key, quoteDotenvValue(config[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quoteDotenvValue(value string) string {
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// ParseDotenv reads a .env file. It supports comments, an optional export
+// prefix, single quoted literal values, double quoted values with escapes,
+// multiline quoted values and $VAR, ${VAR} and ${VAR:-default} interpolation
+// in unquoted and double quoted values. Variables are resolved from earlier
+// lines of the file, then with lookup if it is set.
+func ParseDotenv(r io.Reader, lookup func(key string) (string, bool)) (map[string]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &dotenvParser{
+		src:    strings.ReplaceAll(string(data), "\r\n", "\n"),
+		line:   1,
+		values: map[string]string{},
+		lookup: lookup,
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.values, nil
+}
+
+type dotenvParser struct {
+	src    string
+	pos    int
+	line   int
+	values map[string]string
+	lookup func(key string) (string, bool)
+}
+
+func (p *dotenvParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("dotenv line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *dotenvParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *dotenvParser) next() byte {
+	ch := p.src[p.pos]
+	p.pos++
+	if ch == '\n' {
+		p.line++
+	}
+	return ch
+}
+
+func (p *dotenvParser) skipBlanks() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	for p.pos < len(p.src) && p.next() != '\n' {
+	}
+}
+
+func (p *dotenvParser) parse() error {
+	for p.pos < len(p.src) {
+		p.skipBlanks()
+		switch p.peek() {
+		case '\n':
+			p.next()
+			continue
+		case '#':
+			p.skipLine()
+			continue
+		case 0:
+			return nil
+		}
+
+		key := p.readKey()
+		if key == "export" && (p.peek() == ' ' || p.peek() == '\t') {
+			p.skipBlanks()
+			key = p.readKey()
+		}
+		if key == "" {
+			return p.errorf("expected a variable name")
+		}
+		if err := validateConfigKey(key); err != nil {
+			return p.errorf("%s", err.Error())
+		}
+
+		p.skipBlanks()
+		if p.peek() != '=' {
+			return p.errorf("expected '=' after '%s'", key)
+		}
+		p.pos++
+		p.skipBlanks()
+
+		value, err := p.readValue()
+		if err != nil {
+			return err
+		}
+		p.values[key] = value
+
+		p.skipBlanks()
+		switch p.peek() {
+		case '#':
+			p.skipLine()
+		case '\n':
+			p.next()
+		case 0:
+		default:
+			return p.errorf("unexpected '%c' after value of '%s'", p.peek(), key)
+		}
+	}
+	return nil
+}
+
+func (p *dotenvParser) readKey() string {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n=#", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *dotenvParser) readValue() (string, error) {
+	switch p.peek() {
+	case '\'':
+		p.pos++
+		end := strings.IndexByte(p.src[p.pos:], '\'')
+		if end < 0 {
+			return "", p.errorf("unterminated single quote")
+		}
+		value := p.src[p.pos : p.pos+end]
+		p.line += strings.Count(value, "\n")
+		p.pos += end + 1
+		return value, nil
+	case '"':
+		p.pos++
+		var value strings.Builder
+		for {
+			if p.pos >= len(p.src) {
+				return "", p.errorf("unterminated double quote")
+			}
+			ch := p.next()
+			switch ch {
+			case '"':
+				return value.String(), nil
+			case '\\':
+				if p.pos >= len(p.src) {
+					return "", p.errorf("unterminated double quote")
+				}
+				escaped := p.next()
+				switch escaped {
+				case 'n':
+					value.WriteByte('\n')
+				case 'r':
+					value.WriteByte('\r')
+				case 't':
+					value.WriteByte('\t')
+				case '\n':
+				default:
+					value.WriteByte(escaped)
+				}
+			case '$':
+				if err := p.interpolate(&value); err != nil {
+					return "", err
+				}
+			default:
+				value.WriteByte(ch)
+			}
+		}
+	}
+
+	var value strings.Builder
+	for p.pos < len(p.src) && p.peek() != '\n' {
+		// an inline comment has to be preceded by whitespace
+		if p.peek() == '#' && (value.Len() == 0 || strings.HasSuffix(value.String(), " ") || strings.HasSuffix(value.String(), "\t")) {
+			break
+		}
+		ch := p.next()
+		if ch == '$' {
+			if err := p.interpolate(&value); err != nil {
+				return "", err
+			}
+			continue
+		}
+		value.WriteByte(ch)
+	}
+	return strings.TrimRight(value.String(), " \t"), nil
+}
+
+// interpolate expands the variable following a '$'.
+func (p *dotenvParser) interpolate(value *strings.Builder) error {
+	if p.peek() == '{' {
+		p.pos++
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return p.errorf("unterminated variable reference")
+		}
+		expr := p.src[p.pos : p.pos+end]
+		p.pos += end + 1
+
+		name, fallback, hasDefault := strings.Cut(expr, ":-")
+		resolved, ok := p.resolve(name)
+		if (!ok || resolved == "") && hasDefault {
+			resolved = fallback
+		}
+		value.WriteString(resolved)
+		return nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isDotenvNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		value.WriteByte('$')
+		return nil
+	}
+	resolved, _ := p.resolve(p.src[start:p.pos])
+	value.WriteString(resolved)
+	return nil
+}
+
+func (p *dotenvParser) resolve(name string) (string, bool) {
+	if value, ok := p.values[name]; ok {
+		return value, true
+	}
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+	return "", false
+}
+
+func isDotenvNameChar(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,137 @@
+package dokku
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenv(t *testing.T) {
+	env := `# database settings
+export DB_HOST=db.internal
+DB_PORT = 5432   # inline comment
+DATABASE_URL="postgres://${DB_HOST}:$DB_PORT/app"
+LITERAL='no $DB_HOST interpolation # here'
+ESCAPED="tab\there \"quoted\" \$DB_HOST"
+MULTILINE="line one
+line two"
+CERT='-----BEGIN-----
+abc
+-----END-----'
+FALLBACK=${MISSING:-default}
+FROM_LOOKUP=$HOME
+HASH=color#fff
+EMPTY=
+`
+	lookup := func(key string) (string, bool) {
+		if key == "HOME" {
+			return "/home/app", true
+		}
+		return "", false
+	}
+	values, err := ParseDotenv(strings.NewReader(env), lookup)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_HOST":      "db.internal",
+		"DB_PORT":      "5432",
+		"DATABASE_URL": "postgres://db.internal:5432/app",
+		"LITERAL":      "no $DB_HOST interpolation # here",
+		"ESCAPED":      "tab\there \"quoted\" $DB_HOST",
+		"MULTILINE":    "line one\nline two",
+		"CERT":         "-----BEGIN-----\nabc\n-----END-----",
+		"FALLBACK":     "default",
+		"FROM_LOOKUP":  "/home/app",
+		"HASH":         "color#fff",
+		"EMPTY":        "",
+	}, values)
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	for _, env := range []string{
+		"KEY",
+		"KEY='unterminated",
+		"KEY=\"unterminated",
+		"BAD-KEY=1",
+		"KEY='value' trailing",
+		"KEY=${UNTERMINATED",
+	} {
+		_, err := ParseDotenv(strings.NewReader(env), nil)
+		assert.Error(t, err, env)
+	}
+
+	_, err := ParseDotenv(strings.NewReader("A=1\n\nB='x\ny'\nC"), nil)
+	assert.ErrorContains(t, err, "line 5")
+}
+
+func TestImportDotenv(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{
+		"KEEP":              "same",
+		"CHANGE":            "old",
+		"STALE":             "value",
+		"DOKKU_APP_RESTORE": "1",
+	}))
+	fe.on("ps:report api", testRunningProcessReport)
+
+	env := "KEEP=same\nCHANGE=new\n"
+	diff, err := client.ImportDotenv("api", strings.NewReader(env), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "~CHANGE", diff.String())
+	assert.Equal(t, []string{
+		"config:bundle api",
+		"config:set --no-restart --encoded api CHANGE='bmV3'",
+		"ps:report api",
+		"ps:restart --parallel 1 api",
+	}, fe.commands)
+
+	fe.commands = nil
+	diff, err = client.ImportDotenv("api", strings.NewReader(env), &DotenvImportOptions{Prune: true, NoRestart: true})
+	require.NoError(t, err)
+	assert.Equal(t, "~CHANGE -STALE", diff.String())
+	assert.Equal(t, []string{
+		"config:bundle api",
+		"config:set --no-restart --encoded api CHANGE='bmV3'",
+		"config:unset --no-restart api STALE",
+	}, fe.commands)
+}
+
+func TestExportDotenv(t *testing.T) {
+	config := map[string]string{
+		"PLAIN":     "value with spaces",
+		"QUOTE":     "it's $HOME\n\"here\"",
+		"MULTILINE": "line one\nline two",
+		"EMPTY":     "",
+	}
+	client, fe := newFakeClient()
+	fe.on("config:bundle api", testConfigBundle(t, config))
+
+	var buf bytes.Buffer
+	require.NoError(t, client.ExportDotenv("api", &buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "EMPTY=''\n"))
+
+	parsed, err := ParseDotenv(&buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, config, parsed)
+}
+
+func TestDotenvSkipsManagedKeys(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{
+		"PORT":                 "5000",
+		"DOKKU_PROXY_PORT_MAP": "http:80:5000",
+		"GIT_REV":              "abc123",
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, client.ExportDotenv("api", &buf))
+	assert.Equal(t, "PORT='5000'\n", buf.String())
+
+	env := "PORT=5000\nDOKKU_PROXY_PORT_MAP=http:80:3000\nGIT_REV=def456\n"
+	diff, err := client.ImportDotenv("api", strings.NewReader(env), &DotenvImportOptions{Prune: true})
+	require.NoError(t, err)
+	assert.Empty(t, diff.String())
+	assert.Equal(t, []string{"config:bundle api", "config:bundle api"}, fe.commands)
+}
@@ -0,0 +1,141 @@
+package dokku
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type FormationOptions struct {
+	// scale without deploying, the new formation is used on the next deploy
+	SkipDeploy bool
+}
+
+const (
+	scaleTableHeader    = "proctype"
+	appJSONFormationKey = "formation"
+	appJSONQuantityKey  = "quantity"
+)
+
+// parseProcessScale parses the ps:scale table, e.g.
+//
+//	-----> Scaling for app
+//	proctype: qty
+//	--------: ---
+//	web:  1
+func parseProcessScale(output string) (map[string]int, error) {
+	scale := map[string]int{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		// skip blank lines, headings and warnings
+		if line == "" || strings.HasPrefix(line, "----->") || strings.HasPrefix(line, "=====>") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		name, qty, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid process scale line '%s'", line)
+		}
+		name = strings.TrimSpace(name)
+		qty = strings.TrimSpace(qty)
+		if name == scaleTableHeader || (name != "" && strings.Trim(name, "-") == "") {
+			continue
+		}
+		n, err := strconv.Atoi(qty)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid scale '%s' for process '%s'", qty, name)
+		}
+		scale[name] = n
+	}
+	return scale, nil
+}
+
+func formationAssignments(formation map[string]int) (string, error) {
+	if len(formation) == 0 {
+		return "", fmt.Errorf("formation is empty")
+	}
+	assignments := make([]string, 0, len(formation))
+	for _, process := range sortedKeys(formation) {
+		if process == "" || strings.ContainsAny(process, "= \t\n") {
+			return "", fmt.Errorf("invalid process type '%s'", process)
+		}
+		if formation[process] < 0 {
+			return "", fmt.Errorf("invalid scale %d for process '%s'", formation[process], process)
+		}
+		assignments = append(assignments, fmt.Sprintf("%s=%d", process, formation[process]))
+	}
+	return strings.Join(assignments, " "), nil
+}
+
+// SetAppFormation scales all given process types with a single ps:scale,
+// so the app is deployed at most once.
+func (c *BaseClient) SetAppFormation(appName string, formation map[string]int, options *FormationOptions) (*CommandOutputStream, error) {
+	assignments, err := formationAssignments(formation)
+	if err != nil {
+		return nil, err
+	}
+	cmd := fmt.Sprintf(psScaleCommand, appName, assignments)
+	if options != nil && options.SkipDeploy {
+		cmd += " --skip-deploy"
+	}
+	return c.ExecStreaming(cmd)
+}
+
+// GetAppJSONFormation reads the process quantities from the formation
+// section of an app.json document. Process types without a quantity are
+// left out.
+func GetAppJSONFormation(appJSON []byte) (map[string]int, error) {
+	var doc struct {
+		Formation map[string]struct {
+			Quantity *int `json:"quantity"`
+		} `json:"formation"`
+	}
+	if err := json.Unmarshal(appJSON, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse app.json: %w", err)
+	}
+	formation := map[string]int{}
+	for process, settings := range doc.Formation {
+		if settings.Quantity != nil {
+			formation[process] = *settings.Quantity
+		}
+	}
+	return formation, nil
+}
+
+// SetAppJSONFormation sets the process quantities in the formation section
+// of an app.json document, keeping everything else. Dokku applies the
+// formation from app.json when the app is deployed.
+func SetAppJSONFormation(appJSON []byte, formation map[string]int) ([]byte, error) {
+	if _, err := formationAssignments(formation); err != nil {
+		return nil, err
+	}
+
+	doc := map[string]json.RawMessage{}
+	if len(strings.TrimSpace(string(appJSON))) > 0 {
+		if err := json.Unmarshal(appJSON, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse app.json: %w", err)
+		}
+	}
+
+	sections := map[string]map[string]json.RawMessage{}
+	if raw, ok := doc[appJSONFormationKey]; ok {
+		if err := json.Unmarshal(raw, &sections); err != nil {
+			return nil, fmt.Errorf("failed to parse app.json formation: %w", err)
+		}
+	}
+	for process, quantity := range formation {
+		section := sections[process]
+		if section == nil {
+			section = map[string]json.RawMessage{}
+			sections[process] = section
+		}
+		section[appJSONQuantityKey] = json.RawMessage(strconv.Itoa(quantity))
+	}
+
+	raw, err := json.Marshal(sections)
+	if err != nil {
+		return nil, err
+	}
+	doc[appJSONFormationKey] = raw
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,84 @@
+package dokku
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcessScale(t *testing.T) {
+	scale, err := parseProcessScale("-----> Scaling for api\nproctype: qty\n--------: ---\nweb:  2\nworker: 0\n")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"web": 2, "worker": 0}, scale)
+
+	scale, err = parseProcessScale("")
+	require.NoError(t, err)
+	assert.Empty(t, scale)
+
+	scale, err = parseProcessScale(" !     some warning\nweb: 1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"web": 1}, scale)
+
+	for _, output := range []string{
+		"web",
+		"web:",
+		"web: many",
+		"web: -1",
+		":",
+	} {
+		assert.NotPanics(t, func() {
+			_, err := parseProcessScale(output)
+			assert.Error(t, err, output)
+		})
+	}
+}
+
+func TestSetAppFormation(t *testing.T) {
+	client, fe := newFakeClient()
+
+	stream, err := client.SetAppFormation("api", map[string]int{"worker": 3, "web": 2}, &FormationOptions{SkipDeploy: true})
+	require.NoError(t, err)
+	require.NoError(t, stream.Wait())
+	assert.Equal(t, []string{"ps:scale api web=2 worker=3 --skip-deploy"}, fe.commands)
+
+	_, err = client.SetAppFormation("api", map[string]int{"web": -1}, nil)
+	assert.Error(t, err)
+	_, err = client.SetAppFormation("api", map[string]int{"we b": 1}, nil)
+	assert.Error(t, err)
+	_, err = client.SetAppFormation("api", map[string]int{}, nil)
+	assert.Error(t, err)
+}
+
+func TestAppJSONFormation(t *testing.T) {
+	appJSON := []byte(`{
+  "name": "api",
+  "formation": {
+    "web": {"quantity": 1, "max_parallel": 2},
+    "release": {"autoscaling": {}}
+  }
+}`)
+	formation, err := GetAppJSONFormation(appJSON)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"web": 1}, formation)
+
+	updated, err := SetAppJSONFormation(appJSON, map[string]int{"web": 3, "worker": 1})
+	require.NoError(t, err)
+
+	formation, err = GetAppJSONFormation(updated)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"web": 3, "worker": 1}, formation)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(updated, &doc))
+	assert.Equal(t, "api", doc["name"])
+	web := doc["formation"].(map[string]interface{})["web"].(map[string]interface{})
+	assert.Equal(t, float64(2), web["max_parallel"])
+
+	created, err := SetAppJSONFormation(nil, map[string]int{"web": 1})
+	require.NoError(t, err)
+	formation, err = GetAppJSONFormation(created)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"web": 1}, formation)
+}
@@ -1,7 +1,9 @@
 package dokku
 
 import (
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/parkerdgabel/dokku-go/internal/reports"
 )
@@ -9,17 +11,23 @@ import (
 type gitManager interface {
 	GitInitializeApp(appName string) error
 	GitGetPublicKey() (string, error)
+	GitGenerateDeployKey() (string, error)
+	GitGetAppStatus(appName string) (*GitStatus, error)
 	GitSyncAppRepo(appName string, repo string, opt *GitSyncOptions) (*CommandOutputStream, error)
 	GitCreateFromArchive(appName string, url string, opt *GitArchiveOptions) (*CommandOutputStream, error)
+	GitDeployArchive(appName string, archive io.Reader, opt *GitArchiveOptions) (*CommandOutputStream, error)
 	GitCreateFromImage(appName string, image string, opt *GitImageOptions) (*CommandOutputStream, error)
+	GitLoadImage(appName string, image string, r io.Reader, opt *GitImageOptions) (*CommandOutputStream, error)
 	GitSetAuth(host string, username string, password string) error
 	GitRemoveAuth(host string) error
 	GitSetAppProperty(appName string, property GitProperty, val string) error
 	GitRemoveAppProperty(appName string, property GitProperty) error
+	GitSetGlobalProperty(property GitProperty, val string) error
 	GitAllowHost(host string) error
 	GitUnlockApp(appName string, force bool) error
 	GitGetAppReport(appName string) (*GitAppReport, error)
 	GitGetReport() (GitReport, error)
+	PushDeploy(appName string, source PushSource, ref string) (*CommandOutputStream, string, error)
 
 	GitRunRepoGC(appName string) error
 	GitPurgeRepoCache(appName string) error
@@ -31,6 +39,7 @@ type GitAppReport struct {
 	KeepGitDir         bool   `json:"keep_git_dir" dokku:"Git keep git dir"`
 	RevisionEnvVar     string `json:"rev_env_var" dokku:"Git rev env var"`
 	SHA                string `json:"sha" dokku:"Git sha"`
+	SourceImage        string `json:"source_image" dokku:"Git source image"`
 	LastUpdatedAt      string `json:"last_updated_at" dokku:"Git last updated at"`
 }
 
@@ -42,6 +51,7 @@ const (
 	GitPropertyDeployBranch = GitProperty("deploy-branch")
 	GitPropertyRevEnvVar    = GitProperty("rev-env-var")
 	GitPropertyKeepGitDir   = GitProperty("keep-git-dir")
+	GitPropertySourceImage  = GitProperty("source-image")
 )
 
 const (
@@ -49,10 +59,13 @@ const (
 	gitAuthCmd            = "git:auth %s %s"
 	gitFromArchiveCmd     = "git:from-archive --archive-type %s %s %s %s"
 	gitFromImageCmd       = "git:from-image %s %s %s %s"
+	gitGenerateKeyCmd     = "git:generate-deploy-key"
 	gitInitializeCmd      = "git:initialize %s"
+	gitLoadImageCmd       = "git:load-image %s %s %s %s"
 	gitPublicKeyCmd       = "git:public-key"
 	gitReportCmd          = "git:report %s"
 	gitSetCmd             = "git:set %s %s %s"
+	gitStatusCmd          = "git:status %s"
 	gitSyncCmd            = "git:sync %s %s"
 	gitSyncWithOptionsCmd = "git:sync %s %s %s %s"
 	gitUnlockCmd          = "git:unlock %s %s"
@@ -69,6 +82,10 @@ type GitArchiveOptions struct {
 type GitImageOptions struct {
 	BuildDir      string
 	AuthorDetails *GitAuthorDetails
+
+	// optional, called with the total bytes sent while GitLoadImage sends
+	// the image. It's called from the goroutine sending the image.
+	Progress func(bytesSent int64)
 }
 
 type GitAuthorDetails struct {
@@ -137,7 +154,73 @@ func (c *BaseClient) GitCreateFromImage(appName string, image string, opt *GitIm
 	return c.ExecStreaming(cmd)
 }
 
+// GitLoadImage deploys the app from an image in the format of `docker save`,
+// sent over the client's connection, so no registry is needed. image is the
+// name of the image in the archive.
+func (c *BaseClient) GitLoadImage(appName string, image string, r io.Reader, opt *GitImageOptions) (*CommandOutputStream, error) {
+	if image == "" {
+		return nil, errors.New("an image name is required")
+	}
+	var authorDetails string
+	buildDir := ""
+	if opt != nil {
+		if opt.AuthorDetails != nil {
+			authorDetails = opt.AuthorDetails.String()
+		}
+		if opt.BuildDir != "" {
+			buildDir = "--build-dir " + opt.BuildDir
+		}
+		if opt.Progress != nil {
+			r = &progressReader{r: r, progress: opt.Progress}
+		}
+	}
+	cmd := fmt.Sprintf(gitLoadImageCmd, buildDir, appName, image, authorDetails)
+	return c.ExecWithInputStreaming(cmd, r)
+}
+
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	progress func(bytesSent int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.progress(p.sent)
+	}
+	return n, err
+}
+
+// GitGenerateDeployKey generates the ssh key dokku uses for git:sync of
+// private repositories, and returns its public key.
+func (c *BaseClient) GitGenerateDeployKey() (string, error) {
+	if _, err := c.Exec(gitGenerateKeyCmd); err != nil {
+		return "", err
+	}
+	return c.GitGetPublicKey()
+}
+
+// GitSetAuth sets the credentials dokku uses for the host. git:auth only
+// takes the credentials as arguments, not on stdin, so they are quoted for
+// the shell when connected as a user other than dokku. As the dokku user,
+// the command reaches dokku split on whitespace with no way to quote it, so
+// credentials containing whitespace can't be set and return an error rather
+// than being sent split up.
 func (c *BaseClient) GitSetAuth(host string, username string, password string) error {
+	args := []string{}
+	for _, arg := range []struct{ name, value string }{{"host", host}, {"username", username}, {"password", password}} {
+		if arg.value == "" {
+			return fmt.Errorf("git auth %s is required", arg.name)
+		}
+		quoted, ok := c.quoteArg(arg.value)
+		if !ok {
+			return fmt.Errorf("git auth %s can't contain whitespace when connected as the dokku user", arg.name)
+		}
+		args = append(args, quoted)
+	}
+	host, username, password = args[0], args[1], args[2]
 	authDetails := fmt.Sprintf("%s %s", username, password)
 	cmd := fmt.Sprintf(gitAuthCmd, host, authDetails)
 	_, err := c.Exec(cmd)
@@ -160,6 +243,10 @@ func (c *BaseClient) GitRemoveAppProperty(appName string, property GitProperty)
 	return c.GitSetAppProperty(appName, property, "")
 }
 
+func (c *BaseClient) GitSetGlobalProperty(property GitProperty, val string) error {
+	return c.GitSetAppProperty("--global", property, val)
+}
+
 func (c *BaseClient) GitAllowHost(host string) error {
 	cmd := fmt.Sprintf(gitAllowHostCmd, host)
 	_, err := c.Exec(cmd)
@@ -0,0 +1,758 @@
+package dokku
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type gitObjectType int
+
+const (
+	gitObjectCommit   = gitObjectType(1)
+	gitObjectTree     = gitObjectType(2)
+	gitObjectBlob     = gitObjectType(3)
+	gitObjectTag      = gitObjectType(4)
+	gitObjectOfsDelta = gitObjectType(6)
+	gitObjectRefDelta = gitObjectType(7)
+
+	gitModeTree       = "40000"
+	gitModeFile       = "100644"
+	gitModeExecutable = "100755"
+	gitModeSubmodule  = "160000"
+
+	gitZeroSHA = "0000000000000000000000000000000000000000"
+)
+
+var gitObjectTypeNames = map[gitObjectType]string{
+	gitObjectCommit: "commit",
+	gitObjectTree:   "tree",
+	gitObjectBlob:   "blob",
+	gitObjectTag:    "tag",
+}
+
+type gitObject struct {
+	typ  gitObjectType
+	data []byte
+}
+
+func (o *gitObject) hash() string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", gitObjectTypeNames[o.typ], len(o.data))
+	h.Write(o.data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GitCommitOptions describe the commit created for a deploy from files.
+type GitCommitOptions struct {
+	// optional, defaults to "Deploy"
+	Message string
+	// optional, default to dokku-go <dokku-go@localhost>
+	AuthorName  string
+	AuthorEmail string
+	// optional, defaults to now
+	Time time.Time
+}
+
+func encodeGitCommit(tree string, parents []string, options *GitCommitOptions) *gitObject {
+	opts := GitCommitOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Message == "" {
+		opts.Message = "Deploy"
+	}
+	if opts.AuthorName == "" {
+		opts.AuthorName = "dokku-go"
+	}
+	if opts.AuthorEmail == "" {
+		opts.AuthorEmail = "dokku-go@localhost"
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+
+	signature := fmt.Sprintf("%s <%s> %d %s", opts.AuthorName, opts.AuthorEmail, opts.Time.Unix(), opts.Time.Format("-0700"))
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tree %s\n", tree)
+	for _, parent := range parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&buf, "author %s\ncommitter %s\n\n%s\n", signature, signature, strings.TrimRight(opts.Message, "\n"))
+	return &gitObject{typ: gitObjectCommit, data: buf.Bytes()}
+}
+
+type gitTreeEntry struct {
+	mode string
+	name string
+	sha  string
+}
+
+func encodeGitTree(entries []gitTreeEntry) (*gitObject, error) {
+	// git sorts directories as if their name ended with a slash
+	sortKey := func(e gitTreeEntry) string {
+		if e.mode == gitModeTree {
+			return e.name + "/"
+		}
+		return e.name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortKey(entries[i]) < sortKey(entries[j])
+	})
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		raw, err := hex.DecodeString(entry.sha)
+		if err != nil || len(raw) != sha1.Size {
+			return nil, fmt.Errorf("invalid object id '%s'", entry.sha)
+		}
+		fmt.Fprintf(&buf, "%s %s\x00", entry.mode, entry.name)
+		buf.Write(raw)
+	}
+	return &gitObject{typ: gitObjectTree, data: buf.Bytes()}, nil
+}
+
+func decodeGitTree(data []byte) ([]gitTreeEntry, error) {
+	entries := []gitTreeEntry{}
+	for len(data) > 0 {
+		mode, rest, ok := bytes.Cut(data, []byte(" "))
+		if !ok {
+			return nil, errors.New("invalid tree entry mode")
+		}
+		name, rest, ok := bytes.Cut(rest, []byte{0})
+		if !ok || len(rest) < sha1.Size {
+			return nil, errors.New("invalid tree entry")
+		}
+		entries = append(entries, gitTreeEntry{
+			mode: string(mode),
+			name: string(name),
+			sha:  hex.EncodeToString(rest[:sha1.Size]),
+		})
+		data = rest[sha1.Size:]
+	}
+	return entries, nil
+}
+
+// buildGitTree adds the blobs and trees of dir in fsys to objects, and
+// returns the id of the tree. Empty directories are left out like git does,
+// as are files which are neither regular files nor directories.
+func buildGitTree(fsys fs.FS, dir string, objects map[string]*gitObject) (string, error) {
+	dirEntries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+
+	entries := []gitTreeEntry{}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		entryPath := path.Join(dir, name)
+		if name == ".git" {
+			continue
+		}
+
+		switch {
+		case dirEntry.IsDir():
+			sha, err := buildGitTree(fsys, entryPath, objects)
+			if err != nil {
+				return "", err
+			}
+			if sha != "" {
+				entries = append(entries, gitTreeEntry{mode: gitModeTree, name: name, sha: sha})
+			}
+		case dirEntry.Type().IsRegular():
+			data, err := fs.ReadFile(fsys, entryPath)
+			if err != nil {
+				return "", err
+			}
+			info, err := dirEntry.Info()
+			if err != nil {
+				return "", err
+			}
+			mode := gitModeFile
+			if info.Mode().Perm()&0111 != 0 {
+				mode = gitModeExecutable
+			}
+			blob := &gitObject{typ: gitObjectBlob, data: data}
+			sha := blob.hash()
+			objects[sha] = blob
+			entries = append(entries, gitTreeEntry{mode: mode, name: name, sha: sha})
+		}
+	}
+	if len(entries) == 0 && dir != "." {
+		return "", nil
+	}
+
+	tree, err := encodeGitTree(entries)
+	if err != nil {
+		return "", err
+	}
+	sha := tree.hash()
+	objects[sha] = tree
+	return sha, nil
+}
+
+// parseGitCommit returns the tree and parents of a commit.
+func parseGitCommit(data []byte) (string, []string) {
+	var tree string
+	var parents []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "tree "); ok {
+			tree = value
+		} else if value, ok := strings.CutPrefix(line, "parent "); ok {
+			parents = append(parents, value)
+		}
+	}
+	return tree, parents
+}
+
+func packObjectHeader(typ gitObjectType, size int) []byte {
+	header := []byte{byte(typ)<<4 | byte(size&0x0f)}
+	size >>= 4
+	for size > 0 {
+		header[len(header)-1] |= 0x80
+		header = append(header, byte(size&0x7f))
+		size >>= 7
+	}
+	return header
+}
+
+// writePackfile writes the objects as a version 2 packfile without deltas.
+func writePackfile(w io.Writer, objects []*gitObject) error {
+	h := sha1.New()
+	mw := io.MultiWriter(w, h)
+
+	header := make([]byte, 12)
+	copy(header, "PACK")
+	binary.BigEndian.PutUint32(header[4:], 2)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(objects)))
+	if _, err := mw.Write(header); err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if _, err := mw.Write(packObjectHeader(obj.typ, len(obj.data))); err != nil {
+			return err
+		}
+		zw := zlib.NewWriter(mw)
+		if _, err := zw.Write(obj.data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(h.Sum(nil))
+	return err
+}
+
+// gitRepository reads objects and refs of a local repository, without
+// needing the git binary.
+type gitRepository struct {
+	// holds HEAD, and the refs of a worktree
+	gitDir string
+	// holds objects and shared refs
+	commonDir string
+	packs     []*gitPack
+}
+
+type gitPack struct {
+	file    *os.File
+	offsets map[string]int64
+	cache   map[int64]*gitObject
+}
+
+func openGitRepository(repoPath string) (*gitRepository, error) {
+	gitDir := filepath.Join(repoPath, ".git")
+	info, err := os.Stat(gitDir)
+	switch {
+	case err == nil && !info.IsDir():
+		// a worktree or submodule, with a file pointing at the git dir
+		content, err := os.ReadFile(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+		if !ok {
+			return nil, fmt.Errorf("invalid .git file in '%s'", repoPath)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(repoPath, target)
+		}
+		gitDir = target
+	case err != nil:
+		// a bare repository
+		gitDir = repoPath
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, fmt.Errorf("'%s' is not a git repository", repoPath)
+	}
+
+	repo := &gitRepository{gitDir: gitDir, commonDir: gitDir}
+	if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(content))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.commonDir = commonDir
+	}
+
+	indexes, err := filepath.Glob(filepath.Join(repo.commonDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		pack, err := openGitPack(index)
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+		repo.packs = append(repo.packs, pack)
+	}
+	return repo, nil
+}
+
+func (r *gitRepository) Close() error {
+	var errs []error
+	for _, pack := range r.packs {
+		errs = append(errs, pack.file.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func isGitSHA(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// resolve returns the commit a revision points to. Revisions are full object
+// ids or ref names, looked up like git does.
+func (r *gitRepository) resolve(revision string) (string, error) {
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	sha := ""
+	if isGitSHA(revision) {
+		sha = strings.ToLower(revision)
+	} else {
+		for _, name := range []string{revision, "refs/" + revision, "refs/tags/" + revision, "refs/heads/" + revision, "refs/remotes/" + revision} {
+			found, err := r.readRef(name, 0)
+			if err != nil {
+				return "", err
+			}
+			if found != "" {
+				sha = found
+				break
+			}
+		}
+		if sha == "" {
+			return "", fmt.Errorf("unknown revision '%s'", revision)
+		}
+	}
+
+	// peel annotated tags
+	for {
+		obj, err := r.readObject(sha)
+		if err != nil {
+			return "", err
+		}
+		switch obj.typ {
+		case gitObjectCommit:
+			return sha, nil
+		case gitObjectTag:
+			target, _, _ := strings.Cut(string(obj.data), "\n")
+			sha = strings.TrimPrefix(target, "object ")
+		default:
+			return "", fmt.Errorf("revision '%s' is not a commit", revision)
+		}
+	}
+}
+
+// readRef returns the object id of a ref, or an empty string if it doesn't
+// exist.
+func (r *gitRepository) readRef(name string, depth int) (string, error) {
+	if depth > 5 {
+		return "", fmt.Errorf("too many levels of symbolic refs for '%s'", name)
+	}
+	for _, dir := range []string{r.gitDir, r.commonDir} {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(content))
+		if target, ok := strings.CutPrefix(value, "ref: "); ok {
+			return r.readRef(target, depth+1)
+		}
+		if isGitSHA(value) {
+			return value, nil
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(r.commonDir, "packed-refs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		sha, ref, ok := strings.Cut(line, " ")
+		if ok && ref == name {
+			return sha, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *gitRepository) readObject(sha string) (*gitObject, error) {
+	loose, err := os.Open(filepath.Join(r.commonDir, "objects", sha[:2], sha[2:]))
+	if err == nil {
+		defer loose.Close()
+		return readLooseObject(loose)
+	}
+	for _, pack := range r.packs {
+		if offset, ok := pack.offsets[sha]; ok {
+			return pack.readObject(r, offset)
+		}
+	}
+	return nil, fmt.Errorf("object %s not found", sha)
+}
+
+func readLooseObject(r io.Reader) (*gitObject, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	header, data, ok := bytes.Cut(raw, []byte{0})
+	if !ok {
+		return nil, errors.New("invalid loose object")
+	}
+	typeName, _, _ := strings.Cut(string(header), " ")
+	for typ, name := range gitObjectTypeNames {
+		if name == typeName {
+			return &gitObject{typ: typ, data: data}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid loose object type '%s'", typeName)
+}
+
+// openGitPack reads a version 2 pack index, and opens its pack.
+func openGitPack(indexPath string) (*gitPack, error) {
+	index, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	const headerSize = 8 + 256*4
+	if len(index) < headerSize || !bytes.Equal(index[:8], []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}) {
+		return nil, fmt.Errorf("unsupported pack index '%s'", indexPath)
+	}
+	count := int(binary.BigEndian.Uint32(index[headerSize-4 : headerSize]))
+	shas := index[headerSize:]
+	if len(shas) < count*(sha1.Size+8) {
+		return nil, fmt.Errorf("truncated pack index '%s'", indexPath)
+	}
+	// the crc32 of every object is between the ids and the offsets
+	offsets := shas[count*sha1.Size+count*4:]
+	largeOffsets := offsets[count*4:]
+
+	pack := &gitPack{offsets: make(map[string]int64, count), cache: map[int64]*gitObject{}}
+	for i := 0; i < count; i++ {
+		sha := hex.EncodeToString(shas[i*sha1.Size : (i+1)*sha1.Size])
+		offset := int64(binary.BigEndian.Uint32(offsets[i*4:]))
+		if offset&0x80000000 != 0 {
+			large := int(offset&0x7fffffff) * 8
+			if large+8 > len(largeOffsets) {
+				return nil, fmt.Errorf("truncated pack index '%s'", indexPath)
+			}
+			offset = int64(binary.BigEndian.Uint64(largeOffsets[large:]))
+		}
+		pack.offsets[sha] = offset
+	}
+
+	pack.file, err = os.Open(strings.TrimSuffix(indexPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+func (p *gitPack) readObject(repo *gitRepository, offset int64) (*gitObject, error) {
+	if obj, ok := p.cache[offset]; ok {
+		return obj, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	typ := gitObjectType((b >> 4) & 0x07)
+	size := int(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		size |= int(b&0x7f) << shift
+	}
+
+	var base *gitObject
+	switch typ {
+	case gitObjectCommit, gitObjectTree, gitObjectBlob, gitObjectTag:
+	case gitObjectOfsDelta:
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		distance := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+			distance = (distance+1)<<7 | int64(b&0x7f)
+		}
+		if base, err = p.readObject(repo, offset-distance); err != nil {
+			return nil, err
+		}
+	case gitObjectRefDelta:
+		raw := make([]byte, sha1.Size)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil, err
+		}
+		if base, err = repo.readObject(hex.EncodeToString(raw)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid pack object type %d", typ)
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != size {
+		return nil, errors.New("invalid pack object size")
+	}
+
+	obj := &gitObject{typ: typ, data: data}
+	if base != nil {
+		if obj.data, err = applyGitDelta(base.data, data); err != nil {
+			return nil, err
+		}
+		obj.typ = base.typ
+	}
+	p.cache[offset] = obj
+	return obj, nil
+}
+
+func readDeltaSize(delta []byte) (int, []byte) {
+	size := 0
+	for shift := 0; len(delta) > 0; shift += 7 {
+		b := delta[0]
+		delta = delta[1:]
+		size |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return size, delta
+}
+
+func applyGitDelta(base []byte, delta []byte) ([]byte, error) {
+	baseSize, delta := readDeltaSize(delta)
+	if baseSize != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	size, delta := readDeltaSize(delta)
+
+	result := make([]byte, 0, size)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			offset, length := 0, 0
+			for i := 0; i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errors.New("truncated delta")
+				}
+				if i < 4 {
+					offset |= int(delta[0]) << (8 * i)
+				} else {
+					length |= int(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if length == 0 {
+				length = 0x10000
+			}
+			if offset+length > len(base) {
+				return nil, errors.New("delta copy out of range")
+			}
+			result = append(result, base[offset:offset+length]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("invalid delta instruction")
+		}
+	}
+	if len(result) != size {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return result, nil
+}
+
+// missingObjects returns the commits reachable from tip which aren't
+// reachable from any of the commits in have, with the trees and blobs the
+// server doesn't have yet. The server is assumed to have the trees and blobs
+// of the commits the new ones build on, so unchanged files aren't sent
+// again. Objects are read into memory, so pushing a large first commit
+// takes about as much memory as its files.
+func (r *gitRepository) missingObjects(tip string, have []string) ([]*gitObject, error) {
+	excluded := map[string]bool{}
+	queue := []string{}
+	for _, sha := range have {
+		// the server may have commits we don't know
+		if obj, err := r.readObject(sha); err == nil && obj.typ == gitObjectCommit {
+			queue = append(queue, sha)
+		}
+	}
+	for len(queue) > 0 {
+		sha := queue[0]
+		queue = queue[1:]
+		if excluded[sha] {
+			continue
+		}
+		excluded[sha] = true
+		obj, err := r.readObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		_, parents := parseGitCommit(obj.data)
+		queue = append(queue, parents...)
+	}
+
+	// the commits to send, and the commits of the server they build on
+	commits := []*gitObject{}
+	boundary := map[string]bool{}
+	seen := map[string]bool{}
+	queue = []string{tip}
+	for len(queue) > 0 {
+		sha := queue[0]
+		queue = queue[1:]
+		if excluded[sha] {
+			boundary[sha] = true
+			continue
+		}
+		if seen[sha] {
+			continue
+		}
+		seen[sha] = true
+		commit, err := r.readObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+		_, parents := parseGitCommit(commit.data)
+		queue = append(queue, parents...)
+	}
+
+	// known holds the trees and blobs which are on the server or in the pack
+	known := map[string]bool{}
+	objects := []*gitObject{}
+	var walkTree func(sha string, send bool) error
+	walkTree = func(sha string, send bool) error {
+		if known[sha] {
+			return nil
+		}
+		known[sha] = true
+		tree, err := r.readObject(sha)
+		if err != nil {
+			return err
+		}
+		if send {
+			objects = append(objects, tree)
+		}
+		entries, err := decodeGitTree(tree.data)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			switch {
+			case entry.mode == gitModeSubmodule:
+				// submodule commits aren't part of the repository
+			case entry.mode == gitModeTree:
+				if err := walkTree(entry.sha, send); err != nil {
+					return err
+				}
+			case !known[entry.sha]:
+				known[entry.sha] = true
+				if !send {
+					continue
+				}
+				blob, err := r.readObject(entry.sha)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, blob)
+			}
+		}
+		return nil
+	}
+
+	for _, sha := range sortedKeys(boundary) {
+		commit, err := r.readObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		tree, _ := parseGitCommit(commit.data)
+		if err := walkTree(tree, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, commit := range commits {
+		objects = append(objects, commit)
+		tree, _ := parseGitCommit(commit.data)
+		if err := walkTree(tree, true); err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
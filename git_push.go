@@ -0,0 +1,365 @@
+package dokku
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// PushSource provides the commit and objects sent by PushDeploy.
+type PushSource interface {
+	// pushObjects returns the commit to push and the objects the server
+	// needs for it. old is the commit the pushed ref points to on the
+	// server, or the zero id, and have holds every commit the server
+	// advertised.
+	pushObjects(old string, have []string) (string, []*gitObject, error)
+}
+
+type repositoryPushSource struct {
+	path     string
+	revision string
+}
+
+// NewRepositoryPushSource pushes a revision of a local git repository, e.g.
+// "HEAD", "main" or a commit id. path is the worktree or a bare repository.
+func NewRepositoryPushSource(path string, revision string) PushSource {
+	return &repositoryPushSource{path: path, revision: revision}
+}
+
+func (s *repositoryPushSource) pushObjects(old string, have []string) (string, []*gitObject, error) {
+	repo, err := openGitRepository(s.path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer repo.Close()
+
+	sha, err := repo.resolve(s.revision)
+	if err != nil {
+		return "", nil, err
+	}
+	if sha == old {
+		return sha, nil, nil
+	}
+	objects, err := repo.missingObjects(sha, have)
+	if err != nil {
+		return "", nil, err
+	}
+	return sha, objects, nil
+}
+
+type fsPushSource struct {
+	fsys    fs.FS
+	options *GitCommitOptions
+}
+
+// NewFSPushSource pushes the files of fsys as a new commit, on top of the
+// commit the branch points to on the server so the push is a fast-forward.
+// Files other than regular files and directories, like symlinks, are left
+// out, as is a .git directory.
+func NewFSPushSource(fsys fs.FS, options *GitCommitOptions) PushSource {
+	return &fsPushSource{fsys: fsys, options: options}
+}
+
+func (s *fsPushSource) pushObjects(old string, have []string) (string, []*gitObject, error) {
+	objects := map[string]*gitObject{}
+	tree, err := buildGitTree(s.fsys, ".", objects)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read files: %w", err)
+	}
+
+	var parents []string
+	if old != gitZeroSHA {
+		parents = append(parents, old)
+	}
+	commit := encodeGitCommit(tree, parents, s.options)
+	sha := commit.hash()
+	objects[sha] = commit
+
+	packed := make([]*gitObject, 0, len(objects))
+	for _, key := range sortedKeys(objects) {
+		packed = append(packed, objects[key])
+	}
+	return sha, packed, nil
+}
+
+// GitPushError is returned when the server rejects a push, e.g. because
+// the deploy failed in the pre-receive hook.
+type GitPushError struct {
+	Ref    string
+	Reason string
+}
+
+func (e *GitPushError) Error() string {
+	return fmt.Sprintf("push to '%s' rejected: %s", e.Ref, e.Reason)
+}
+
+const (
+	gitReceivePackCmd = "git-receive-pack '%s'"
+
+	gitCapabilityReportStatus = "report-status"
+	gitCapabilitySideBand     = "side-band-64k"
+	gitAgent                  = "agent=dokku-go"
+
+	gitSideBandData     = 1
+	gitSideBandProgress = 2
+	gitSideBandError    = 3
+)
+
+// readPktLine reads a git pkt-line. A flush packet is returned as nil.
+func readPktLine(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pkt-line length '%s'", header)
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if length < 4 {
+		return nil, fmt.Errorf("invalid pkt-line length %d", length)
+	}
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writePktLine(w io.Writer, line string) error {
+	_, err := fmt.Fprintf(w, "%04x%s", len(line)+4, line)
+	return err
+}
+
+func writeFlushPkt(w io.Writer) error {
+	_, err := io.WriteString(w, "0000")
+	return err
+}
+
+// readRefAdvertisement reads the refs and capabilities the server sends when
+// receive-pack starts.
+func readRefAdvertisement(r io.Reader) (map[string]string, map[string]bool, []string, error) {
+	refs := map[string]string{}
+	capabilities := map[string]bool{}
+	have := []string{}
+	for first := true; ; first = false {
+		line, err := readPktLine(r)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read refs: %w", err)
+		}
+		if line == nil {
+			return refs, capabilities, have, nil
+		}
+
+		text := strings.TrimSuffix(string(line), "\n")
+		if first {
+			var caps string
+			text, caps, _ = strings.Cut(text, "\x00")
+			for _, capability := range strings.Fields(caps) {
+				capabilities[capability] = true
+			}
+		}
+		sha, name, ok := strings.Cut(text, " ")
+		if !ok || !isGitSHA(sha) {
+			return nil, nil, nil, fmt.Errorf("invalid ref advertisement '%s'", text)
+		}
+		if sha == gitZeroSHA || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		have = append(have, sha)
+		if name != ".have" {
+			refs[name] = sha
+		}
+	}
+}
+
+// readReportStatus reads the result of the push.
+func readReportStatus(r io.Reader, ref string) error {
+	for {
+		line, err := readPktLine(r)
+		if err != nil {
+			return fmt.Errorf("failed to read push status: %w", err)
+		}
+		if line == nil {
+			return nil
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		switch {
+		case strings.HasPrefix(text, "unpack "):
+			if status := strings.TrimPrefix(text, "unpack "); status != "ok" {
+				return &GitPushError{Ref: ref, Reason: "unpack failed: " + status}
+			}
+		case strings.HasPrefix(text, "ng "):
+			name, reason, _ := strings.Cut(strings.TrimPrefix(text, "ng "), " ")
+			return &GitPushError{Ref: name, Reason: reason}
+		}
+	}
+}
+
+// PushDeploy deploys by pushing to the app's git repository with the git
+// protocol, over the client's connection and without the git binary. ref is
+// the branch pushed to, and defaults to the app's deploy branch. The build
+// output is streamed as it happens, and the pushed commit is returned.
+func (c *BaseClient) PushDeploy(appName string, source PushSource, ref string) (*CommandOutputStream, string, error) {
+	if ref == "" {
+		report, err := c.GitGetAppReport(appName)
+		if err != nil {
+			return nil, "", err
+		}
+		ref = report.DeployBranch
+		if ref == "" {
+			ref = report.GlobalDeployBranch
+		}
+		if ref == "" {
+			ref = "master"
+		}
+	}
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	input, inputWriter := io.Pipe()
+	stream, err := c.ExecWithInputStreaming(fmt.Sprintf(gitReceivePackCmd, appName), input)
+	if err != nil {
+		return nil, "", err
+	}
+	// closing the input ends receive-pack on errors
+	abort := func(err error) (*CommandOutputStream, string, error) {
+		inputWriter.CloseWithError(err)
+		if waitErr := stream.Wait(); waitErr != nil {
+			return nil, "", fmt.Errorf("%w (%s)", err, waitErr.Error())
+		}
+		return nil, "", err
+	}
+
+	serverOutput := bufio.NewReader(stream.Stdout)
+	refs, capabilities, have, err := readRefAdvertisement(serverOutput)
+	if err != nil {
+		return abort(err)
+	}
+	old, ok := refs[ref]
+	if !ok {
+		old = gitZeroSHA
+	}
+	sha, objects, err := source.pushObjects(old, have)
+	if err != nil {
+		return abort(err)
+	}
+
+	progress, progressWriter := io.Pipe()
+	out := &CommandOutputStream{
+		Stdout: progress,
+		Stderr: stream.Stderr,
+		done:   make(chan struct{}),
+	}
+
+	if sha == old {
+		// nothing to push, an empty command list ends receive-pack
+		go func() {
+			defer close(out.done)
+			err := writeFlushPkt(inputWriter)
+			inputWriter.Close()
+			if err == nil {
+				_, err = io.WriteString(progressWriter, "Everything up-to-date\n")
+			}
+			progressWriter.Close()
+			_, _ = io.Copy(io.Discard, serverOutput)
+			out.Error = waitForStream(stream, err)
+		}()
+		return out, sha, nil
+	}
+
+	requested := []string{gitAgent}
+	for _, capability := range []string{gitCapabilityReportStatus, gitCapabilitySideBand} {
+		if capabilities[capability] {
+			requested = append(requested, capability)
+		}
+	}
+
+	sent := make(chan error, 1)
+	go func() {
+		command := fmt.Sprintf("%s %s %s\x00%s\n", old, sha, ref, strings.Join(requested, " "))
+		err := writePktLine(inputWriter, command)
+		if err == nil {
+			err = writeFlushPkt(inputWriter)
+		}
+		if err == nil {
+			err = writePackfile(inputWriter, objects)
+		}
+		inputWriter.CloseWithError(err)
+		sent <- err
+	}()
+
+	go func() {
+		defer close(out.done)
+		err := readPushResult(serverOutput, progressWriter, ref, capabilities)
+		progressWriter.Close()
+		_, _ = io.Copy(io.Discard, serverOutput)
+		if sendErr := <-sent; err == nil && sendErr != nil {
+			err = fmt.Errorf("failed to send objects: %w", sendErr)
+		}
+		out.Error = waitForStream(stream, err)
+	}()
+
+	return out, sha, nil
+}
+
+// readPushResult copies the progress of the push to w, and returns the push
+// status.
+func readPushResult(r io.Reader, w io.Writer, ref string, capabilities map[string]bool) error {
+	if !capabilities[gitCapabilitySideBand] {
+		if !capabilities[gitCapabilityReportStatus] {
+			_, err := io.Copy(w, r)
+			return err
+		}
+		return readReportStatus(r, ref)
+	}
+
+	var status bytes.Buffer
+	for {
+		line, err := readPktLine(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read push output: %w", err)
+		}
+		if line == nil {
+			break
+		}
+		if len(line) == 0 {
+			continue
+		}
+		switch line[0] {
+		case gitSideBandData:
+			status.Write(line[1:])
+		case gitSideBandProgress:
+			_, _ = w.Write(line[1:])
+		case gitSideBandError:
+			return &GitPushError{Ref: ref, Reason: strings.TrimSpace(string(line[1:]))}
+		}
+	}
+
+	if !capabilities[gitCapabilityReportStatus] {
+		return nil
+	}
+	return readReportStatus(&status, ref)
+}
+
+// waitForStream waits for a stream whose output is read elsewhere, and
+// returns err, or the error of the stream.
+func waitForStream(stream *CommandOutputStream, err error) error {
+	if stream.done != nil {
+		<-stream.done
+	}
+	if err != nil {
+		return err
+	}
+	return stream.Error
+}
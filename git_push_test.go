@@ -0,0 +1,207 @@
+package dokku
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receivePackExecutor answers git-receive-pack with a local bare repository,
+// like dokku does over ssh.
+type receivePackExecutor struct {
+	repo string
+}
+
+func (e *receivePackExecutor) exec(cmd string, input io.Reader) (string, error) {
+	return "", NotImplementedError
+}
+
+func (e *receivePackExecutor) execStreaming(cmd string, input io.Reader) (*CommandOutputStream, error) {
+	if !strings.HasPrefix(cmd, "git-receive-pack ") {
+		return nil, NotImplementedError
+	}
+	command := exec.Command("git", "receive-pack", e.repo)
+	command.Stdin = input
+	// own pipes, as Wait closes the pipes of StdoutPipe before they are read
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	command.Stdout = stdoutWriter
+	command.Stderr = stderrWriter
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	stream := &CommandOutputStream{Stdout: stdout, Stderr: stderr, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		stream.Error = command.Wait()
+		stdoutWriter.Close()
+		stderrWriter.Close()
+	}()
+	return stream, nil
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	command.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+dir,
+	)
+	out, err := command.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func newPushTestClient(t *testing.T) (*BaseClient, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	remote := t.TempDir()
+	runGit(t, remote, "init", "-q", "--bare")
+	return &BaseClient{executor: &receivePackExecutor{repo: remote}}, remote
+}
+
+func readPush(t *testing.T, stream *CommandOutputStream) (string, error) {
+	t.Helper()
+	progress, err := io.ReadAll(stream.Stdout)
+	require.NoError(t, err)
+	return string(progress), stream.Wait()
+}
+
+func TestPushDeployRepository(t *testing.T) {
+	client, remote := newPushTestClient(t)
+
+	local := t.TempDir()
+	runGit(t, local, "init", "-q")
+	runGit(t, local, "checkout", "-q", "-b", "main")
+	content := strings.Repeat("line of text\n", 200)
+	require.NoError(t, os.WriteFile(filepath.Join(local, "app.txt"), []byte(content), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(local, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(local, "bin", "run"), []byte("#!/bin/sh\n"), 0755))
+	runGit(t, local, "add", ".")
+	runGit(t, local, "commit", "-q", "-m", "first")
+	require.NoError(t, os.WriteFile(filepath.Join(local, "app.txt"), []byte(content+"more\n"), 0644))
+	runGit(t, local, "commit", "-q", "-am", "second")
+	// packs the history with deltas, and leaves the next commit loose
+	runGit(t, local, "gc", "-q", "--aggressive")
+	runGit(t, local, "tag", "-a", "v1", "-m", "release")
+	require.NoError(t, os.WriteFile(filepath.Join(local, "app.txt"), []byte(content+"even more\n"), 0644))
+	runGit(t, local, "commit", "-q", "-am", "third")
+
+	stream, sha, err := client.PushDeploy("api", NewRepositoryPushSource(local, "v1"), "master")
+	require.NoError(t, err)
+	_, err = readPush(t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, runGit(t, local, "rev-parse", "HEAD~1"), sha)
+	assert.Equal(t, sha, runGit(t, remote, "rev-parse", "refs/heads/master"))
+
+	// only the new commit is sent
+	stream, sha, err = client.PushDeploy("api", NewRepositoryPushSource(local, "HEAD"), "master")
+	require.NoError(t, err)
+	_, err = readPush(t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, runGit(t, local, "rev-parse", "HEAD"), sha)
+	assert.Equal(t, sha, runGit(t, remote, "rev-parse", "refs/heads/master"))
+	runGit(t, remote, "fsck", "--strict")
+
+	stream, _, err = client.PushDeploy("api", NewRepositoryPushSource(local, "main"), "master")
+	require.NoError(t, err)
+	progress, err := readPush(t, stream)
+	require.NoError(t, err)
+	assert.Contains(t, progress, "up-to-date")
+
+	_, _, err = client.PushDeploy("api", NewRepositoryPushSource(local, "unknown"), "master")
+	assert.ErrorContains(t, err, "unknown revision")
+}
+
+func TestPushDeployFS(t *testing.T) {
+	client, remote := newPushTestClient(t)
+
+	files := fstest.MapFS{
+		"Procfile":       {Data: []byte("web: ./bin/run\n")},
+		"bin/run":        {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+		"lib/a/b/c.txt":  {Data: []byte("c")},
+		"lib-other.txt":  {Data: []byte("sorted before lib/")},
+		".git/HEAD":      {Data: []byte("ignored")},
+		"empty/dir":      {Mode: fs.ModeDir},
+		"docs/README.md": {Data: []byte("docs")},
+	}
+	stream, first, err := client.PushDeploy("api", NewFSPushSource(files, &GitCommitOptions{Message: "first"}), "main")
+	require.NoError(t, err)
+	_, err = readPush(t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, first, runGit(t, remote, "rev-parse", "refs/heads/main"))
+
+	files["Procfile"] = &fstest.MapFile{Data: []byte("web: ./bin/run --port $PORT\n")}
+	stream, second, err := client.PushDeploy("api", NewFSPushSource(files, nil), "main")
+	require.NoError(t, err)
+	_, err = readPush(t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, second, runGit(t, remote, "rev-parse", "refs/heads/main"))
+	assert.Equal(t, first, runGit(t, remote, "rev-parse", "main~1"))
+	runGit(t, remote, "fsck", "--strict")
+
+	tree := runGit(t, remote, "ls-tree", "-r", "main")
+	assert.Contains(t, tree, "100755 blob")
+	assert.Contains(t, tree, "bin/run")
+	assert.Contains(t, tree, "lib/a/b/c.txt")
+	assert.NotContains(t, tree, ".git")
+	assert.NotContains(t, tree, "empty")
+}
+
+func TestPushDeployRejected(t *testing.T) {
+	client, remote := newPushTestClient(t)
+	hook := filepath.Join(remote, "hooks", "pre-receive")
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\necho '-----> Building api'\nexit 1\n"), 0755))
+
+	stream, _, err := client.PushDeploy("api", NewFSPushSource(fstest.MapFS{"a": {Data: []byte("a")}}, nil), "master")
+	require.NoError(t, err)
+	progress, err := readPush(t, stream)
+	assert.Contains(t, progress, "-----> Building api")
+	var pushErr *GitPushError
+	require.ErrorAs(t, err, &pushErr)
+	assert.Equal(t, "refs/heads/master", pushErr.Ref)
+	assert.Contains(t, pushErr.Reason, "pre-receive hook declined")
+}
+
+func TestMissingObjects(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	local := t.TempDir()
+	runGit(t, local, "init", "-q")
+	require.NoError(t, os.MkdirAll(filepath.Join(local, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(local, "lib", "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(local, "b.txt"), []byte("b"), 0644))
+	runGit(t, local, "add", ".")
+	runGit(t, local, "commit", "-q", "-m", "first")
+	require.NoError(t, os.WriteFile(filepath.Join(local, "b.txt"), []byte("changed"), 0644))
+	runGit(t, local, "commit", "-q", "-am", "second")
+
+	repo, err := openGitRepository(local)
+	require.NoError(t, err)
+	defer repo.Close()
+	objects, err := repo.missingObjects(runGit(t, local, "rev-parse", "HEAD"), []string{runGit(t, local, "rev-parse", "HEAD~1")})
+	require.NoError(t, err)
+
+	var sent []string
+	for _, obj := range objects {
+		sent = append(sent, obj.hash())
+	}
+	assert.ElementsMatch(t, []string{
+		runGit(t, local, "rev-parse", "HEAD"),
+		runGit(t, local, "rev-parse", "HEAD^{tree}"),
+		runGit(t, local, "rev-parse", "HEAD:b.txt"),
+	}, sent)
+}
@@ -0,0 +1,137 @@
+package dokku
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GitStatus is the working tree status of an app's repository, as shown by
+// git:status.
+type GitStatus struct {
+	Branch string
+	// set instead of Branch when HEAD doesn't point to a branch
+	Detached string
+	// commits ahead of and behind the upstream branch
+	Ahead  int
+	Behind int
+
+	Staged    []GitStatusEntry
+	Unstaged  []GitStatusEntry
+	Untracked []string
+	Unmerged  []GitStatusEntry
+}
+
+// GitStatusEntry is a changed file, e.g. Status "modified".
+type GitStatusEntry struct {
+	Status string
+	Path   string
+	// the path before a rename
+	OldPath string
+}
+
+// Clean reports whether the working tree has no changes.
+func (s *GitStatus) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0 && len(s.Unmerged) == 0
+}
+
+var (
+	gitStatusAheadRe    = regexp.MustCompile(`is ahead of '[^']+' by (\d+) commits?`)
+	gitStatusBehindRe   = regexp.MustCompile(`is behind '[^']+' by (\d+) commits?`)
+	gitStatusDivergedRe = regexp.MustCompile(`have (\d+) and (\d+) different commits each`)
+	gitStatusEntryRe    = regexp.MustCompile(`^([a-z ]+):\s+(.+)$`)
+)
+
+type gitStatusSection int
+
+const (
+	gitStatusNoSection gitStatusSection = iota
+	gitStatusStagedSection
+	gitStatusUnstagedSection
+	gitStatusUntrackedSection
+	gitStatusUnmergedSection
+)
+
+// ParseGitStatus parses the long format output of git status.
+func ParseGitStatus(output string) (*GitStatus, error) {
+	status := &GitStatus{}
+	section := gitStatusNoSection
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "fatal:"):
+			return nil, fmt.Errorf("git status failed: %s", trimmed)
+		case strings.HasPrefix(trimmed, "On branch "):
+			status.Branch = strings.TrimPrefix(trimmed, "On branch ")
+		case strings.HasPrefix(trimmed, "HEAD detached at "):
+			status.Detached = strings.TrimPrefix(trimmed, "HEAD detached at ")
+		case strings.HasPrefix(trimmed, "HEAD detached from "):
+			status.Detached = strings.TrimPrefix(trimmed, "HEAD detached from ")
+		case strings.HasPrefix(trimmed, "Your branch "):
+			if m := gitStatusAheadRe.FindStringSubmatch(trimmed); m != nil {
+				status.Ahead, _ = strconv.Atoi(m[1])
+			}
+			if m := gitStatusBehindRe.FindStringSubmatch(trimmed); m != nil {
+				status.Behind, _ = strconv.Atoi(m[1])
+			}
+		case gitStatusDivergedRe.MatchString(trimmed):
+			m := gitStatusDivergedRe.FindStringSubmatch(trimmed)
+			status.Ahead, _ = strconv.Atoi(m[1])
+			status.Behind, _ = strconv.Atoi(m[2])
+		case trimmed == "Changes to be committed:":
+			section = gitStatusStagedSection
+		case trimmed == "Changes not staged for commit:":
+			section = gitStatusUnstagedSection
+		case trimmed == "Untracked files:":
+			section = gitStatusUntrackedSection
+		case trimmed == "Unmerged paths:":
+			section = gitStatusUnmergedSection
+		case strings.HasPrefix(trimmed, "("):
+			// hints like (use "git add <file>..." to update what will be committed)
+			continue
+		case !strings.HasPrefix(line, "\t"):
+			// summary lines like "nothing to commit, working tree clean"
+			section = gitStatusNoSection
+		case section == gitStatusUntrackedSection:
+			status.Untracked = append(status.Untracked, trimmed)
+		case section != gitStatusNoSection:
+			entry, err := parseGitStatusEntry(trimmed)
+			if err != nil {
+				return nil, err
+			}
+			switch section {
+			case gitStatusStagedSection:
+				status.Staged = append(status.Staged, entry)
+			case gitStatusUnstagedSection:
+				status.Unstaged = append(status.Unstaged, entry)
+			case gitStatusUnmergedSection:
+				status.Unmerged = append(status.Unmerged, entry)
+			}
+		}
+	}
+	return status, nil
+}
+
+func parseGitStatusEntry(line string) (GitStatusEntry, error) {
+	m := gitStatusEntryRe.FindStringSubmatch(line)
+	if m == nil {
+		return GitStatusEntry{}, fmt.Errorf("invalid git status entry '%s'", line)
+	}
+	entry := GitStatusEntry{Status: m[1], Path: m[2]}
+	if oldPath, path, ok := strings.Cut(entry.Path, " -> "); ok {
+		entry.OldPath, entry.Path = oldPath, path
+	}
+	return entry, nil
+}
+
+func (c *BaseClient) GitGetAppStatus(appName string) (*GitStatus, error) {
+	cmd := fmt.Sprintf(gitStatusCmd, appName)
+	output, err := c.Exec(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGitStatus(output)
+}
@@ -0,0 +1,77 @@
+package dokku
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGitStatus = `On branch master
+Your branch and 'origin/master' have diverged,
+and have 2 and 1 different commits each, respectively.
+  (use "git pull" to merge the remote branch into yours)
+
+Changes to be committed:
+  (use "git restore --staged <file>..." to unstage)
+	new file:   app.json
+	renamed:    Procfile.old -> Procfile
+
+Changes not staged for commit:
+  (use "git add <file>..." to update what will be committed)
+  (use "git restore <file>..." to discard changes in working directory)
+	modified:   package.json
+	deleted:    README.md
+
+Untracked files:
+  (use "git add <file>..." to include in what will be committed)
+	.env
+	tmp/
+
+`
+
+func TestParseGitStatus(t *testing.T) {
+	status, err := ParseGitStatus(testGitStatus)
+	require.NoError(t, err)
+
+	assert.Equal(t, "master", status.Branch)
+	assert.Equal(t, 2, status.Ahead)
+	assert.Equal(t, 1, status.Behind)
+	assert.Equal(t, []GitStatusEntry{
+		{Status: "new file", Path: "app.json"},
+		{Status: "renamed", Path: "Procfile", OldPath: "Procfile.old"},
+	}, status.Staged)
+	assert.Equal(t, []GitStatusEntry{
+		{Status: "modified", Path: "package.json"},
+		{Status: "deleted", Path: "README.md"},
+	}, status.Unstaged)
+	assert.Equal(t, []string{".env", "tmp/"}, status.Untracked)
+	assert.False(t, status.Clean())
+}
+
+func TestParseGitStatusClean(t *testing.T) {
+	status, err := ParseGitStatus("HEAD detached at 4f2a9c1\nnothing to commit, working tree clean\n")
+	require.NoError(t, err)
+	assert.Equal(t, "", status.Branch)
+	assert.Equal(t, "4f2a9c1", status.Detached)
+	assert.True(t, status.Clean())
+
+	status, err = ParseGitStatus("On branch main\nYour branch is behind 'origin/main' by 3 commits, and can be fast-forwarded.\n\nnothing to commit, working tree clean\n")
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.Ahead)
+	assert.Equal(t, 3, status.Behind)
+}
+
+func TestGitGetAppStatus(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("git:status api", testGitStatus)
+
+	status, err := client.GitGetAppStatus("api")
+	require.NoError(t, err)
+	assert.Equal(t, "master", status.Branch)
+	assert.Len(t, status.Unstaged, 2)
+
+	fe.on("git:status broken", "fatal: not a git repository (or any of the parent directories): .git")
+	_, err = client.GitGetAppStatus("broken")
+	assert.Error(t, err)
+}
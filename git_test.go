@@ -1,9 +1,13 @@
 package dokku
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -47,3 +51,86 @@ func (s *gitManagerTestSuite) TestSyncGitRepo() {
 	r.NoError(err)
 	r.NotEmpty(stream.Stdout)
 }
+
+// testImageArchive builds a tarball laid out like `docker save` output.
+func testImageArchive(t *testing.T, image string) []byte {
+	var layer bytes.Buffer
+	lw := tar.NewWriter(&layer)
+	content := bytes.Repeat([]byte("x"), 64*1024)
+	require.NoError(t, lw.WriteHeader(&tar.Header{Name: "app/server", Mode: 0755, Size: int64(len(content))}))
+	_, err := lw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, lw.Close())
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"manifest.json", []byte(`[{"Config":"config.json","RepoTags":["` + image + `"],"Layers":["layer/layer.tar"]}]`)},
+		{"config.json", []byte(`{"config":{"Cmd":["/app/server"]}}`)},
+		{"layer/layer.tar", layer.Bytes()},
+	}
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data))}))
+		_, err := tw.Write(file.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return archive.Bytes()
+}
+
+func TestGitLoadImage(t *testing.T) {
+	client, fe := newFakeClient()
+	archive := testImageArchive(t, "api:ci-123")
+
+	var progress []int64
+	stream, err := client.GitLoadImage("api", "api:ci-123", bytes.NewReader(archive), &GitImageOptions{
+		BuildDir: "services/api",
+		Progress: func(bytesSent int64) {
+			progress = append(progress, bytesSent)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, stream.Wait())
+
+	cmd := "git:load-image --build-dir services/api api api:ci-123"
+	assert.Equal(t, []string{cmd}, fe.commands)
+	assert.Equal(t, archive, fe.inputs[cmd])
+	require.NotEmpty(t, progress)
+	assert.Equal(t, int64(len(archive)), progress[len(progress)-1])
+	for i := 1; i < len(progress); i++ {
+		assert.Greater(t, progress[i], progress[i-1])
+	}
+
+	_, err = client.GitLoadImage("api", "", bytes.NewReader(archive), nil)
+	assert.Error(t, err)
+}
+
+func TestGitGenerateDeployKey(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("git:public-key", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIB dokku@host")
+
+	key, err := client.GitGenerateDeployKey()
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIB dokku@host", key)
+	assert.Equal(t, []string{"git:generate-deploy-key", "git:public-key"}, fe.commands)
+}
+
+func TestGitSetAuth(t *testing.T) {
+	client, fe := newFakeClient()
+
+	require.NoError(t, client.GitSetAuth("github.com", "deploy-bot", "ghp_abc123"))
+	require.NoError(t, client.GitSetAuth("github.com", "deploy-bot", `it's"$HOME";*`))
+	assert.Equal(t, []string{
+		"git:auth github.com deploy-bot ghp_abc123",
+		`git:auth github.com deploy-bot it's"$HOME";*`,
+	}, fe.commands)
+
+	for _, password := range []string{"two words", "tab\tsep", "new\nline", ""} {
+		err := client.GitSetAuth("github.com", "deploy-bot", password)
+		assert.Error(t, err, password)
+	}
+	assert.Len(t, fe.commands, 2)
+}
@@ -20,7 +20,7 @@ var (
 	ErrInvalidReport = errors.New("invalid report")
 
 	appNameRe = regexp.MustCompile(`^=====> (\S*)\s`)
-	rowRe     = regexp.MustCompile(`^\s+([\s\w]*):(.*)$`)
+	rowRe     = regexp.MustCompile(`^\s+([\s\w-]*):(.*)$`)
 )
 
 type Report map[string]string
@@ -108,6 +108,9 @@ func ParseIntoMap(rawReport string, reportPtr interface{}) error {
 
 	// this gets kinda gross, but so be it
 	reportVal := reflect.ValueOf(reportPtr)
+	if reflect.Indirect(reportVal).IsNil() {
+		reflect.Indirect(reportVal).Set(reflect.MakeMap(reportVal.Elem().Type()))
+	}
 
 	// Since reports are a map, we want elements of the type held by
 	// the real element passed in reportPtr
@@ -12,6 +12,7 @@ const exampleOutput = `=====> garbage field
        int key:                       3
        boolean key:                   true
        really long key wow it is:     value
+       dashed-plugin key:             dashed
        empty value:       			  `
 
 const exampleOutputWithTwoSections = `=====> APP_NAME blah
@@ -39,6 +40,8 @@ type ExampleIndividualReport struct {
 	BoolKey  bool   `dokku:"boolean key"`
 	LongKey  string `dokku:"really long key wow it is"`
 	EmptyVal string `dokku:"empty value"`
+	// plugins with a dash in their name, e.g. builder-nixpacks
+	DashedKey string `dokku:"dashed-plugin key"`
 }
 
 func TestParseIndividualReport(t *testing.T) {
@@ -49,6 +52,7 @@ func TestParseIndividualReport(t *testing.T) {
 	assert.Equal(t, true, report.BoolKey)
 	assert.Equal(t, "value", report.LongKey)
 	assert.Empty(t, report.EmptyVal)
+	assert.Equal(t, "dashed", report.DashedKey)
 }
 
 type ExampleReport map[string]ExampleIndividualReport
@@ -67,3 +71,9 @@ func TestParseReport(t *testing.T) {
 
 	assert.Error(t, ParseInto(exampleOutputWithMissingKeys, &report))
 }
+
+func TestParseReportNilMap(t *testing.T) {
+	var report ExampleReport
+	assert.NoError(t, ParseIntoMap(exampleOutputWithTwoSections, &report))
+	assert.Contains(t, report, "SECOND_APP")
+}
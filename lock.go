@@ -0,0 +1,270 @@
+package dokku
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+type lockManager interface {
+	WithAppLock(ctx context.Context, appName string, fn func() error) error
+	WithAppLockOptions(ctx context.Context, appName string, options *AppLockOptions, fn func() error) error
+	GetAppLockInfo(appName string) (*AppLockInfo, error)
+	ReapStaleAppLocks() ([]string, error)
+}
+
+type AppLockOptions struct {
+	// optional, defaults to hostname:pid
+	Owner string
+	// optional, defaults to 10 minutes. The lease is renewed while the
+	// locked function is running, so it only lapses if the holder dies.
+	Lease time.Duration
+}
+
+// AppLockInfo is the lock metadata stored in the app config. Owner and
+// ExpiresAt are empty for locks not created by WithAppLock.
+type AppLockInfo struct {
+	Locked    bool
+	Owner     string
+	ExpiresAt time.Time
+}
+
+func (i *AppLockInfo) Stale(now time.Time) bool {
+	return i.Locked && !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
+
+type AppLockHeldError struct {
+	AppName string
+	Info    *AppLockInfo
+}
+
+func (e *AppLockHeldError) Error() string {
+	if e.Info.Owner == "" {
+		return fmt.Sprintf("app '%s' is already locked", e.AppName)
+	}
+	return fmt.Sprintf("app '%s' is already locked by '%s' until %s",
+		e.AppName, e.Info.Owner, e.Info.ExpiresAt.Format(time.RFC3339))
+}
+
+const (
+	lockOwnerConfigKey   = "DOKKU_GO_LOCK_OWNER"
+	lockExpiresConfigKey = "DOKKU_GO_LOCK_EXPIRES_AT"
+	// unique per acquisition, so holders with the same owner are told apart
+	lockTokenConfigKey = "DOKKU_GO_LOCK_TOKEN"
+
+	defaultAppLockLease = 10 * time.Minute
+)
+
+func (o *AppLockOptions) resolve() (string, time.Duration) {
+	var owner string
+	lease := defaultAppLockLease
+	if o != nil {
+		owner = o.Owner
+		if o.Lease > 0 {
+			lease = o.Lease
+		}
+	}
+	if owner == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		owner = fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	}
+	return owner, lease
+}
+
+func (c *BaseClient) WithAppLock(ctx context.Context, appName string, fn func() error) error {
+	return c.WithAppLockOptions(ctx, appName, nil, fn)
+}
+
+// WithAppLockOptions locks the app, runs fn and unlocks the app again, even
+// if fn fails or panics.
+//
+// The lock is advisory: it only keeps out other callers of WithAppLock and
+// deploys, which dokku blocks while the app is locked. dokku can't lock an
+// app atomically, as apps:lock succeeds on a locked app, so the lock is
+// taken by writing a unique token to the app config and reading it back.
+// The last writer holds the lock, and callers which find another token get
+// an AppLockHeldError. Two callers can still both hold the lock if each
+// reads its token back before the other one writes, so the lock must not be
+// relied on where that would be unsafe.
+func (c *BaseClient) WithAppLockOptions(ctx context.Context, appName string, options *AppLockOptions, fn func() error) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := c.GetAppLockInfo(appName)
+	if err != nil {
+		return err
+	}
+	if info.Locked {
+		return &AppLockHeldError{AppName: appName, Info: info}
+	}
+
+	owner, lease := options.resolve()
+	token, err := newAppLockToken()
+	if err != nil {
+		return err
+	}
+	if err := c.setAppLockLease(appName, owner, token, lease); err != nil {
+		return fmt.Errorf("failed to record lock owner: %w", err)
+	}
+	if err := c.LockApp(appName); err != nil {
+		return errors.Join(fmt.Errorf("failed to lock app: %w", err), c.clearAppLockLease(appName, token))
+	}
+	held, err := c.GetAppConfigValue(appName, lockTokenConfigKey, false)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to verify lock: %w", err), c.releaseAppLock(appName))
+	}
+	if held != token {
+		// another caller took the lock at the same time, and holds it now
+		if info, err := c.GetAppLockInfo(appName); err == nil {
+			return &AppLockHeldError{AppName: appName, Info: info}
+		}
+		return &AppLockHeldError{AppName: appName, Info: &AppLockInfo{Locked: true}}
+	}
+
+	stopRenewal := make(chan struct{})
+	renewalDone := make(chan struct{})
+	go func() {
+		defer close(renewalDone)
+		ticker := time.NewTicker(lease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopRenewal:
+				return
+			case <-ticker.C:
+				// a failed renewal is retried on the next tick, and at
+				// worst lets the reaper break the lock early
+				_ = c.setAppLockLease(appName, owner, token, lease)
+			}
+		}
+	}()
+
+	defer func() {
+		close(stopRenewal)
+		<-renewalDone
+		if releaseErr := c.releaseAppLock(appName); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release lock: %w", releaseErr))
+		}
+	}()
+
+	return fn()
+}
+
+func newAppLockToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func (c *BaseClient) setAppLockLease(appName string, owner string, token string, lease time.Duration) error {
+	expiresAt := time.Now().Add(lease).UTC().Format(time.RFC3339)
+	return c.SetAppConfigValues(appName, map[string]string{
+		lockOwnerConfigKey:   owner,
+		lockTokenConfigKey:   token,
+		lockExpiresConfigKey: expiresAt,
+	}, false)
+}
+
+// clearAppLockLease removes the lease written for an app lock which couldn't
+// be taken, unless another caller has written its own lease since.
+func (c *BaseClient) clearAppLockLease(appName string, token string) error {
+	held, err := c.GetAppConfigValue(appName, lockTokenConfigKey, false)
+	if err != nil {
+		return fmt.Errorf("failed to remove lock owner: %w", err)
+	}
+	if held != token {
+		return nil
+	}
+	if err := c.unsetAppLockLease(appName); err != nil {
+		return fmt.Errorf("failed to remove lock owner: %w", err)
+	}
+	return nil
+}
+
+func (c *BaseClient) unsetAppLockLease(appName string) error {
+	keys := []string{lockOwnerConfigKey, lockTokenConfigKey, lockExpiresConfigKey}
+	return c.UnsetAppConfigValues(appName, keys, false)
+}
+
+func (c *BaseClient) releaseAppLock(appName string) error {
+	if err := c.unsetAppLockLease(appName); err != nil {
+		return err
+	}
+	return c.UnlockApp(appName)
+}
+
+func (c *BaseClient) GetAppLockInfo(appName string) (*AppLockInfo, error) {
+	locked, err := c.IsLocked(appName)
+	if err != nil {
+		return nil, err
+	}
+	config, err := c.GetAppConfig(appName)
+	if err != nil {
+		return nil, err
+	}
+	return parseAppLockInfo(locked, config)
+}
+
+func parseAppLockInfo(locked bool, config map[string]string) (*AppLockInfo, error) {
+	info := &AppLockInfo{
+		Locked: locked,
+		Owner:  config[lockOwnerConfigKey],
+	}
+	if expires, ok := config[lockExpiresConfigKey]; ok && expires != "" {
+		expiresAt, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lock expiry '%s': %w", expires, err)
+		}
+		info.ExpiresAt = expiresAt
+	}
+	return info, nil
+}
+
+// ReapStaleAppLocks unlocks apps whose lock lease has expired, returning the
+// names of the apps that were unlocked. Locks created without a lease, e.g.
+// with LockApp, are left alone.
+func (c *BaseClient) ReapStaleAppLocks() ([]string, error) {
+	appReports, err := c.GetAllAppReport()
+	if errors.Is(err, NoDeployedAppsError) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reaped := []string{}
+	for appName, report := range appReports {
+		if !report.IsLocked {
+			continue
+		}
+		config, err := c.GetAppConfig(appName)
+		if err != nil {
+			return reaped, err
+		}
+		info, err := parseAppLockInfo(true, config)
+		if err != nil {
+			return reaped, err
+		}
+		if !info.Stale(now) {
+			continue
+		}
+		if err := c.releaseAppLock(appName); err != nil {
+			return reaped, fmt.Errorf("failed to break lock of '%s': %w", appName, err)
+		}
+		reaped = append(reaped, appName)
+	}
+
+	sort.Strings(reaped)
+	return reaped, nil
+}
@@ -0,0 +1,146 @@
+package dokku
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLockTokenRe = regexp.MustCompile(lockTokenConfigKey + `='([^']*)'`)
+
+// newLockTestClient answers reads of the lock token with the token written
+// last, like dokku would.
+func newLockTestClient() (*BaseClient, *fakeExecutor) {
+	client, fe := newFakeClient()
+	fe.on("apps:locked api", deployLockNotExistsMsg)
+	fe.on("apps:lock api", lockCreatedMsg)
+	fe.on("config:show api", "=====> api env vars")
+	var token string
+	fe.handler = func(cmd string) (string, error, bool) {
+		if m := testLockTokenRe.FindStringSubmatch(cmd); m != nil {
+			decoded, _ := b64.StdEncoding.DecodeString(m[1])
+			token = string(decoded)
+		}
+		if cmd == "config:get api "+lockTokenConfigKey {
+			return token, nil, true
+		}
+		return "", nil, false
+	}
+	return client, fe
+}
+
+func TestWithAppLock(t *testing.T) {
+	client, fe := newLockTestClient()
+
+	ran := false
+	err := client.WithAppLockOptions(context.Background(), "api", &AppLockOptions{Owner: "job-1"}, func() error {
+		ran = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	assert.Regexp(t, `^config:set --no-restart --encoded api `, fe.commands[2])
+	assert.Equal(t, "apps:lock api", fe.commands[3])
+	assert.Equal(t, "config:get api "+lockTokenConfigKey, fe.commands[4])
+	assert.Equal(t, []string{
+		fmt.Sprintf("config:unset --no-restart api %s %s %s", lockOwnerConfigKey, lockTokenConfigKey, lockExpiresConfigKey),
+		"apps:unlock api",
+	}, fe.commands[len(fe.commands)-2:])
+}
+
+func TestWithAppLockLostRace(t *testing.T) {
+	client, fe := newLockTestClient()
+	// another caller wrote its token after ours
+	fe.handler = nil
+	fe.on("config:get api "+lockTokenConfigKey, "other-token")
+
+	err := client.WithAppLock(context.Background(), "api", func() error {
+		t.Fatal("locked function should not run")
+		return nil
+	})
+	var heldErr *AppLockHeldError
+	require.True(t, errors.As(err, &heldErr))
+	assert.NotContains(t, fe.commands, "apps:unlock api", "the lock belongs to the other caller")
+}
+
+func TestWithAppLockFailedLock(t *testing.T) {
+	client, fe := newLockTestClient()
+	lockErr := errors.New("permission denied")
+	fe.fail("apps:lock api", lockErr)
+
+	err := client.WithAppLock(context.Background(), "api", func() error {
+		t.Fatal("locked function should not run")
+		return nil
+	})
+	require.ErrorIs(t, err, lockErr)
+	assert.Equal(t, []string{
+		"config:get api " + lockTokenConfigKey,
+		fmt.Sprintf("config:unset --no-restart api %s %s %s", lockOwnerConfigKey, lockTokenConfigKey, lockExpiresConfigKey),
+	}, fe.commands[len(fe.commands)-2:])
+	assert.NotContains(t, fe.commands, "apps:unlock api")
+}
+
+func TestWithAppLockReleasesOnError(t *testing.T) {
+	client, fe := newLockTestClient()
+
+	jobErr := errors.New("job failed")
+	err := client.WithAppLock(context.Background(), "api", func() error {
+		return jobErr
+	})
+	assert.ErrorIs(t, err, jobErr)
+	assert.Equal(t, "apps:unlock api", fe.commands[len(fe.commands)-1])
+
+	assert.Panics(t, func() {
+		_ = client.WithAppLock(context.Background(), "api", func() error {
+			panic("job panicked")
+		})
+	})
+	assert.Equal(t, "apps:unlock api", fe.commands[len(fe.commands)-1])
+}
+
+func TestWithAppLockHeld(t *testing.T) {
+	client, fe := newLockTestClient()
+	fe.on("apps:locked api", "Deploy lock exists")
+	fe.on("config:show api", fmt.Sprintf("=====> api env vars\n%s: other-job\n%s: 2030-01-01T00:00:00Z",
+		lockOwnerConfigKey, lockExpiresConfigKey))
+
+	err := client.WithAppLock(context.Background(), "api", func() error {
+		t.Fatal("locked function should not run")
+		return nil
+	})
+	var heldErr *AppLockHeldError
+	require.True(t, errors.As(err, &heldErr))
+	assert.Equal(t, "other-job", heldErr.Info.Owner)
+}
+
+func TestReapStaleAppLocks(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("apps:report", `=====> stale app information
+       App locked:                    true
+=====> fresh app information
+       App locked:                    true
+=====> manual app information
+       App locked:                    true
+=====> unlocked app information
+       App locked:                    false`)
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	fe.on("config:show stale", fmt.Sprintf("=====> stale env vars\n%s: %s", lockExpiresConfigKey, expired))
+	fe.on("config:show fresh", fmt.Sprintf("=====> fresh env vars\n%s: %s", lockExpiresConfigKey, valid))
+	fe.on("config:show manual", "=====> manual env vars")
+
+	reaped, err := client.ReapStaleAppLocks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stale"}, reaped)
+	assert.Contains(t, fe.commands, "apps:unlock stale")
+	assert.NotContains(t, fe.commands, "apps:unlock fresh")
+	assert.NotContains(t, fe.commands, "apps:unlock manual")
+}
@@ -41,7 +41,7 @@ const (
 	pluginListCmd                = "plugin:list"
 	pluginTriggerCmd             = "plugin:trigger %s"
 	pluginUninstallCmd           = "plugin:uninstall %s"
-	pluginUpdateCmd              = "plugin:update %s %s"
+	pluginUpdateCmd              = "plugin:update %s"
 )
 
 func (c *BaseClient) ListPlugins() ([]PluginInfo, error) {
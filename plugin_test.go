@@ -3,6 +3,8 @@ package dokku
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -31,3 +33,9 @@ func (s *pluginManagerTestSuite) TestInstallPlugin() {
 	err := s.Client.InstallPlugin(PluginInstallOptions{Url: pluginURL})
 	r.NoError(err)
 }
+
+func TestUpdatePlugin(t *testing.T) {
+	client, fe := newFakeClient()
+	require.NoError(t, client.UpdatePlugin("postgres"))
+	assert.Equal(t, []string{"plugin:update postgres"}, fe.commands)
+}
@@ -1,7 +1,10 @@
 package dokku
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -9,11 +12,12 @@ import (
 )
 
 type processManager interface {
-	GetProcessInfo(appName string) error
+	GetProcessInfo(appName string) ([]*ContainerInfo, error)
 	GetAppProcessReport(appName string) (*AppProcessReport, error)
 	GetAllProcessReport() (ProcessReport, error)
 	GetAppProcessScale(appName string) (map[string]int, error)
 	SetAppProcessScale(appName string, processName string, scale int, skipDeploy bool) (*CommandOutputStream, error)
+	SetAppFormation(appName string, formation map[string]int, options *FormationOptions) (*CommandOutputStream, error)
 	StartApp(appName string, p *ParallelismOptions) (*CommandOutputStream, error)
 	StartAllApps(p *ParallelismOptions) (*CommandOutputStream, error)
 	StopApp(appName string, p *ParallelismOptions) (*CommandOutputStream, error)
@@ -23,15 +27,20 @@ type processManager interface {
 	RestartApp(appName string, p *ParallelismOptions) (*CommandOutputStream, error)
 	RestartAppProcess(appName string, process string, p *ParallelismOptions) (*CommandOutputStream, error)
 	RestartAllApps(p *ParallelismOptions) (*CommandOutputStream, error)
+	RolloutApps(ctx context.Context, options *RolloutOptions) (*RolloutReport, error)
 	SetAppProcfilePath(appName string, procPath string) error
 	SetGlobalProcfilePath(procPath string) error
 	SetAppRestartPolicy(appName string, policy RestartPolicy) error
 	SetGlobalRestartPolicy(policy RestartPolicy) error
-
-	/*
-		SetAppProcessProperty(appName string, key string, value string) error
-		SetGlobalProcessProperty(key string, value string) error
-	*/
+	SetAppStopTimeoutSeconds(appName string, seconds int) error
+	SetGlobalStopTimeoutSeconds(seconds int) error
+	SetAppProcessProperty(appName string, property ProcessProperty, value string) error
+	SetGlobalProcessProperty(property ProcessProperty, value string) error
+	ClearAppProcessProperty(appName string, property ProcessProperty) error
+	ClearGlobalProcessProperty(property ProcessProperty) error
+	RestoreApp(appName string) (*CommandOutputStream, error)
+	RestoreApps() (*CommandOutputStream, error)
+	RetireApps() (*CommandOutputStream, error)
 }
 
 type AppProcessReport struct {
@@ -43,9 +52,39 @@ type AppProcessReport struct {
 	ProcfilePath         string `json:"procfile_path" dokku:"Ps procfile path"`
 	RestartPolicy        string `json:"restart_policy" dokku:"Ps restart policy"`
 	Restore              bool   `json:"restore" dokku:"Restore"`
-	Running              bool   `json:"running" dokku:"Running"`
+	// true only if every process is running
+	Running bool `json:"running" dokku:"-"`
+	// "true", "false" or ProcessRunningMixed
+	RunningState string `json:"running_state" dokku:"Running"`
+
+	ComputedStopTimeoutSeconds int `json:"computed_stop_timeout_seconds" dokku:"Ps computed stop timeout seconds"`
+	GlobalStopTimeoutSeconds   int `json:"global_stop_timeout_seconds" dokku:"Ps global stop timeout seconds"`
+	StopTimeoutSeconds         int `json:"stop_timeout_seconds" dokku:"Ps stop timeout seconds"`
+
+	// parsed from the 'Status <process> <index>' rows
+	Statuses []ProcessStatus `json:"statuses"`
 }
 
+// ProcessStatus is the state of a single container of an app.
+type ProcessStatus struct {
+	Process     string `json:"process"`
+	Index       int    `json:"index"`
+	Status      string `json:"status"`
+	ContainerID string `json:"container_id"`
+}
+
+// ProcessRunningMixed is the RunningState of an app with some of its
+// processes running, e.g. while it restarts or scales up.
+const ProcessRunningMixed = "mixed"
+
+type ProcessProperty string
+
+const (
+	ProcessPropertyProcfilePath       = ProcessProperty("procfile-path")
+	ProcessPropertyRestartPolicy      = ProcessProperty("restart-policy")
+	ProcessPropertyStopTimeoutSeconds = ProcessProperty("stop-timeout-seconds")
+)
+
 type ProcessReport map[string]*AppProcessReport
 
 type RestartPolicy interface {
@@ -100,23 +139,24 @@ const (
 	psRestartCommand           = "ps:restart --parallel %d %s"
 	psRestartAppProcessCommand = "ps:restart --parallel %d %s %s"
 	psRestoreCommand           = "ps:restore %s"
+	psRetireCommand            = "ps:retire"
 	psScaleCommand             = "ps:scale %s %s"
 	psSetCommand               = "ps:set %s %s %s"
 	psStartCommand             = "ps:start --parallel %d %s"
 	psStopCommand              = "ps:stop --parallel %d %s"
 )
 
-func (c *BaseClient) GetProcessInfo(appName string) error {
+func (c *BaseClient) GetProcessInfo(appName string) ([]*ContainerInfo, error) {
 	cmd := fmt.Sprintf(psInspectCommand, appName)
 	output, err := c.Exec(cmd)
 	if err != nil {
-		if strings.HasPrefix(output, "\"docker container inspect\" requires at least 1 argument.") {
-			return AppNotDeployedError
+		if strings.HasPrefix(output, psNotDeployedMsg) {
+			return nil, AppNotDeployedError
 		}
-		return err
+		return nil, err
 	}
 
-	return NotImplementedError
+	return ParseContainerInspect(output)
 }
 
 func (c *BaseClient) GetAppProcessReport(appName string) (*AppProcessReport, error) {
@@ -131,6 +171,8 @@ func (c *BaseClient) GetAppProcessReport(appName string) (*AppProcessReport, err
 	if err := reports.ParseInto(output, &report); err != nil {
 		return nil, err
 	}
+	report.Running = report.RunningState == "true"
+	report.Statuses = parseProcessStatuses(output)
 
 	return &report, nil
 }
@@ -148,36 +190,68 @@ func (c *BaseClient) GetAllProcessReport() (ProcessReport, error) {
 	if err := reports.ParseIntoMap(output, &report); err != nil {
 		return nil, err
 	}
+	for _, appReport := range report {
+		appReport.Running = appReport.RunningState == "true"
+	}
+	for appName, section := range splitReportSections(output) {
+		if appReport, ok := report[appName]; ok {
+			appReport.Statuses = parseProcessStatuses(section)
+		}
+	}
 
 	return report, nil
 }
 
-func (c *BaseClient) GetAppProcessScale(appName string) (map[string]int, error) {
-	cmd := fmt.Sprintf(psScaleCommand, appName, "")
-	output, err := c.Exec(cmd)
-	if err != nil {
-		return nil, err
-	}
-
-	scaleReport := map[string]int{}
+var (
+	reportSectionRe = regexp.MustCompile(`(?m)^=====> (\S+) `)
+	processStatusRe = regexp.MustCompile(`(?m)^\s+Status (\S+) (\d+):\s*(\S+)(?:\s+\(CID:\s*(\w+)\))?`)
+)
 
-	lines := strings.Split(output, "\n")
-	if len(lines) < 3 {
-		return nil, fmt.Errorf("invalid process scale returned")
+// splitReportSections splits a report of all apps into the report of each app.
+func splitReportSections(output string) map[string]string {
+	sections := map[string]string{}
+	matches := reportSectionRe.FindAllStringSubmatchIndex(output, -1)
+	for i, match := range matches {
+		end := len(output)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		sections[output[match[2]:match[3]]] = output[match[0]:end]
 	}
+	return sections
+}
 
-	for i := 3; i < len(lines); i++ {
-		parts := strings.Split(lines[i], ":")
-		processName := parts[0]
-		scale := strings.Trim(parts[1], " :")
-		scaleInt, err := strconv.Atoi(scale)
+func parseProcessStatuses(output string) []ProcessStatus {
+	statuses := []ProcessStatus{}
+	for _, match := range processStatusRe.FindAllStringSubmatch(output, -1) {
+		index, err := strconv.Atoi(match[2])
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert scale (%s): %w", scale, err)
+			continue
 		}
-		scaleReport[processName] = scaleInt
+		statuses = append(statuses, ProcessStatus{
+			Process:     match[1],
+			Index:       index,
+			Status:      match[3],
+			ContainerID: match[4],
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Process != statuses[j].Process {
+			return statuses[i].Process < statuses[j].Process
+		}
+		return statuses[i].Index < statuses[j].Index
+	})
+	return statuses
+}
+
+func (c *BaseClient) GetAppProcessScale(appName string) (map[string]int, error) {
+	cmd := fmt.Sprintf(psScaleCommand, appName, "")
+	output, err := c.Exec(cmd)
+	if err != nil {
+		return nil, err
 	}
 
-	return scaleReport, nil
+	return parseProcessScale(output)
 }
 
 func (c *BaseClient) SetAppProcessScale(appName string, processName string, scale int, skipDeploy bool) (*CommandOutputStream, error) {
@@ -234,36 +308,64 @@ func (c *BaseClient) RestartAllApps(p *ParallelismOptions) (*CommandOutputStream
 	return c.ExecStreaming(cmd)
 }
 
-func (c *BaseClient) setAppProcessProperty(appName string, key string, value string) error {
-	cmd := fmt.Sprintf(psSetCommand, appName, key, value)
+func (c *BaseClient) SetAppProcessProperty(appName string, property ProcessProperty, value string) error {
+	cmd := fmt.Sprintf(psSetCommand, appName, property, value)
 	_, err := c.Exec(cmd)
-	if err != nil {
-		return err
-	}
-	return nil
+	return err
 }
 
-func (c *BaseClient) setGlobalProcessProperty(key string, value string) error {
-	cmd := fmt.Sprintf(psSetCommand, "--global", key, value)
-	_, err := c.Exec(cmd)
-	if err != nil {
-		return err
-	}
-	return nil
+func (c *BaseClient) SetGlobalProcessProperty(property ProcessProperty, value string) error {
+	return c.SetAppProcessProperty("--global", property, value)
+}
+
+func (c *BaseClient) ClearAppProcessProperty(appName string, property ProcessProperty) error {
+	return c.SetAppProcessProperty(appName, property, "")
+}
+
+func (c *BaseClient) ClearGlobalProcessProperty(property ProcessProperty) error {
+	return c.SetGlobalProcessProperty(property, "")
 }
 
 func (c *BaseClient) SetAppProcfilePath(appName string, procPath string) error {
-	return c.setAppProcessProperty(appName, "procfile-path", procPath)
+	return c.SetAppProcessProperty(appName, ProcessPropertyProcfilePath, procPath)
 }
 
 func (c *BaseClient) SetGlobalProcfilePath(procPath string) error {
-	return c.setGlobalProcessProperty("procfile-path", procPath)
+	return c.SetGlobalProcessProperty(ProcessPropertyProcfilePath, procPath)
 }
 
 func (c *BaseClient) SetAppRestartPolicy(appName string, p RestartPolicy) error {
-	return c.setAppProcessProperty(appName, "restart-policy", p.GetPolicy())
+	return c.SetAppProcessProperty(appName, ProcessPropertyRestartPolicy, p.GetPolicy())
 }
 
 func (c *BaseClient) SetGlobalRestartPolicy(p RestartPolicy) error {
-	return c.setGlobalProcessProperty("restart-policy", p.GetPolicy())
+	return c.SetGlobalProcessProperty(ProcessPropertyRestartPolicy, p.GetPolicy())
+}
+
+func (c *BaseClient) SetAppStopTimeoutSeconds(appName string, seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("invalid stop timeout %d", seconds)
+	}
+	return c.SetAppProcessProperty(appName, ProcessPropertyStopTimeoutSeconds, strconv.Itoa(seconds))
+}
+
+func (c *BaseClient) SetGlobalStopTimeoutSeconds(seconds int) error {
+	return c.SetAppStopTimeoutSeconds("--global", seconds)
+}
+
+// RestoreApp starts the app if it was running before, e.g. after a reboot.
+func (c *BaseClient) RestoreApp(appName string) (*CommandOutputStream, error) {
+	cmd := fmt.Sprintf(psRestoreCommand, appName)
+	return c.ExecStreaming(cmd)
+}
+
+// RestoreApps starts all apps which were running before, e.g. after a reboot.
+func (c *BaseClient) RestoreApps() (*CommandOutputStream, error) {
+	cmd := fmt.Sprintf(psRestoreCommand, "")
+	return c.ExecStreaming(cmd)
+}
+
+// RetireApps removes the containers of old deploys once they can be retired.
+func (c *BaseClient) RetireApps() (*CommandOutputStream, error) {
+	return c.ExecStreaming(psRetireCommand)
 }
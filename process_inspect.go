@@ -0,0 +1,220 @@
+package dokku
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContainerInfo is the typed subset of `docker container inspect` output
+// returned by ps:inspect.
+type ContainerInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Image   string    `json:"image"`
+	ImageID string    `json:"image_id"`
+
+	// from the com.dokku.* labels
+	ProcessType string `json:"process_type"`
+	Dyno        string `json:"dyno"`
+
+	State        ContainerState              `json:"state"`
+	RestartCount int                         `json:"restart_count"`
+	Networks     map[string]ContainerNetwork `json:"networks"`
+	Mounts       []ContainerMount            `json:"mounts"`
+	Ports        []ContainerPortBinding      `json:"ports"`
+	Labels       map[string]string           `json:"labels"`
+	// values of keys matching RedactedEnvKeyPatterns are replaced
+	Env map[string]string `json:"env"`
+}
+
+type ContainerState struct {
+	Status     string    `json:"status"`
+	Running    bool      `json:"running"`
+	Paused     bool      `json:"paused"`
+	Restarting bool      `json:"restarting"`
+	OOMKilled  bool      `json:"oom_killed"`
+	Dead       bool      `json:"dead"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// empty if the container has no healthcheck
+	Health string `json:"health"`
+}
+
+type ContainerNetwork struct {
+	IPAddress   string `json:"ip_address"`
+	IPv6Address string `json:"ipv6_address"`
+	Gateway     string `json:"gateway"`
+	MacAddress  string `json:"mac_address"`
+}
+
+type ContainerMount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Mode        string `json:"mode"`
+	ReadWrite   bool   `json:"read_write"`
+}
+
+type ContainerPortBinding struct {
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"host_ip"`
+	HostPort      string `json:"host_port"`
+}
+
+const (
+	dokkuProcessTypeLabel = "com.dokku.process-type"
+	dokkuDynoLabel        = "com.dokku.dyno"
+
+	redactedEnvValue = "<redacted>"
+	psNotDeployedMsg = "\"docker container inspect\" requires at least 1 argument."
+)
+
+// RedactedEnvKeyPatterns are path.Match patterns of the env keys whose
+// values are hidden in ContainerInfo. Keys are matched in upper case.
+var RedactedEnvKeyPatterns = []string{
+	"*KEY*", "*SECRET*", "*TOKEN*", "*PASSWORD*", "*PASSWD*", "*PASS",
+	"*CREDENTIAL*", "*PRIVATE*", "*_URL", "*_URI", "*DSN*",
+}
+
+type dockerInspectContainer struct {
+	ID           string
+	Name         string
+	Created      time.Time
+	Image        string
+	RestartCount int
+	State        struct {
+		Status     string
+		Running    bool
+		Paused     bool
+		Restarting bool
+		OOMKilled  bool
+		Dead       bool
+		ExitCode   int
+		Error      string
+		StartedAt  time.Time
+		FinishedAt time.Time
+		Health     *struct {
+			Status string
+		}
+	}
+	Config struct {
+		Image  string
+		Env    []string
+		Labels map[string]string
+	}
+	Mounts []struct {
+		Type        string
+		Source      string
+		Destination string
+		Mode        string
+		RW          bool
+	}
+	NetworkSettings struct {
+		Ports    map[string][]struct{ HostIp, HostPort string }
+		Networks map[string]struct {
+			IPAddress         string
+			GlobalIPv6Address string
+			Gateway           string
+			MacAddress        string
+		}
+	}
+}
+
+// ParseContainerInspect parses `docker container inspect` JSON output.
+func ParseContainerInspect(output string) ([]*ContainerInfo, error) {
+	// skip anything dokku printed before the JSON array
+	start := strings.Index(output, "[")
+	if start < 0 {
+		return nil, fmt.Errorf("no container inspect output in '%s'", output)
+	}
+
+	var raw []dockerInspectContainer
+	if err := json.Unmarshal([]byte(output[start:]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse container inspect output: %w", err)
+	}
+
+	containers := make([]*ContainerInfo, 0, len(raw))
+	for _, rc := range raw {
+		info := &ContainerInfo{
+			ID:           rc.ID,
+			Name:         strings.TrimPrefix(rc.Name, "/"),
+			Created:      rc.Created,
+			Image:        rc.Config.Image,
+			ImageID:      rc.Image,
+			ProcessType:  rc.Config.Labels[dokkuProcessTypeLabel],
+			Dyno:         rc.Config.Labels[dokkuDynoLabel],
+			RestartCount: rc.RestartCount,
+			State: ContainerState{
+				Status:     rc.State.Status,
+				Running:    rc.State.Running,
+				Paused:     rc.State.Paused,
+				Restarting: rc.State.Restarting,
+				OOMKilled:  rc.State.OOMKilled,
+				Dead:       rc.State.Dead,
+				ExitCode:   rc.State.ExitCode,
+				Error:      rc.State.Error,
+				StartedAt:  rc.State.StartedAt,
+				FinishedAt: rc.State.FinishedAt,
+			},
+			Networks: map[string]ContainerNetwork{},
+			Mounts:   []ContainerMount{},
+			Ports:    []ContainerPortBinding{},
+			Labels:   map[string]string{},
+			Env:      map[string]string{},
+		}
+		if rc.State.Health != nil {
+			info.State.Health = rc.State.Health.Status
+		}
+		for name, network := range rc.NetworkSettings.Networks {
+			info.Networks[name] = ContainerNetwork{
+				IPAddress:   network.IPAddress,
+				IPv6Address: network.GlobalIPv6Address,
+				Gateway:     network.Gateway,
+				MacAddress:  network.MacAddress,
+			}
+		}
+		for _, mount := range rc.Mounts {
+			info.Mounts = append(info.Mounts, ContainerMount{
+				Type:        mount.Type,
+				Source:      mount.Source,
+				Destination: mount.Destination,
+				Mode:        mount.Mode,
+				ReadWrite:   mount.RW,
+			})
+		}
+		for _, port := range sortedKeys(rc.NetworkSettings.Ports) {
+			containerPort, protocol, _ := strings.Cut(port, "/")
+			portNum, err := strconv.Atoi(containerPort)
+			if err != nil {
+				return nil, fmt.Errorf("invalid container port '%s'", port)
+			}
+			for _, binding := range rc.NetworkSettings.Ports[port] {
+				info.Ports = append(info.Ports, ContainerPortBinding{
+					ContainerPort: portNum,
+					Protocol:      protocol,
+					HostIP:        binding.HostIp,
+					HostPort:      binding.HostPort,
+				})
+			}
+		}
+		for key, value := range rc.Config.Labels {
+			info.Labels[key] = value
+		}
+		for _, entry := range rc.Config.Env {
+			key, value, _ := strings.Cut(entry, "=")
+			if matchesAnyPattern(strings.ToUpper(key), RedactedEnvKeyPatterns) {
+				value = redactedEnvValue
+			}
+			info.Env[key] = value
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
@@ -0,0 +1,106 @@
+package dokku
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testContainerInspect = `[
+    {
+        "Id": "0a1b2c3d4e5f",
+        "Created": "2024-05-01T10:00:00.123456789Z",
+        "Name": "/api.web.1",
+        "Image": "sha256:abcdef",
+        "RestartCount": 2,
+        "State": {
+            "Status": "running",
+            "Running": true,
+            "Paused": false,
+            "Restarting": false,
+            "OOMKilled": false,
+            "Dead": false,
+            "Pid": 1234,
+            "ExitCode": 0,
+            "Error": "",
+            "StartedAt": "2024-05-01T10:00:01Z",
+            "FinishedAt": "0001-01-01T00:00:00Z",
+            "Health": {"Status": "healthy", "FailingStreak": 0, "Log": []}
+        },
+        "Mounts": [
+            {"Type": "bind", "Source": "/var/lib/dokku/data/storage/api", "Destination": "/app/storage", "Mode": "", "RW": true}
+        ],
+        "Config": {
+            "Image": "dokku/api:latest",
+            "Env": ["PORT=5000", "DATABASE_URL=postgres://user:pw@db/app", "api_key=abc", "EMPTY="],
+            "Labels": {"com.dokku.app-name": "api", "com.dokku.process-type": "web", "com.dokku.dyno": "web.1"}
+        },
+        "NetworkSettings": {
+            "Ports": {"5000/tcp": [{"HostIp": "0.0.0.0", "HostPort": "32768"}], "9000/udp": null},
+            "Networks": {
+                "bridge": {"IPAddress": "172.17.0.2", "GlobalIPv6Address": "", "Gateway": "172.17.0.1", "MacAddress": "02:42:ac:11:00:02"}
+            }
+        }
+    }
+]`
+
+func TestGetProcessInfo(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:inspect api", testContainerInspect)
+
+	containers, err := client.GetProcessInfo("api")
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+
+	c := containers[0]
+	assert.Equal(t, "0a1b2c3d4e5f", c.ID)
+	assert.Equal(t, "api.web.1", c.Name)
+	assert.Equal(t, "dokku/api:latest", c.Image)
+	assert.Equal(t, "sha256:abcdef", c.ImageID)
+	assert.Equal(t, "web", c.ProcessType)
+	assert.Equal(t, "web.1", c.Dyno)
+	assert.Equal(t, 2, c.RestartCount)
+
+	assert.True(t, c.State.Running)
+	assert.Equal(t, "running", c.State.Status)
+	assert.Equal(t, "healthy", c.State.Health)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC), c.State.StartedAt)
+	assert.True(t, c.State.FinishedAt.IsZero())
+
+	assert.Equal(t, "172.17.0.2", c.Networks["bridge"].IPAddress)
+	assert.Equal(t, []ContainerMount{{
+		Type:        "bind",
+		Source:      "/var/lib/dokku/data/storage/api",
+		Destination: "/app/storage",
+		ReadWrite:   true,
+	}}, c.Mounts)
+	assert.Equal(t, []ContainerPortBinding{{
+		ContainerPort: 5000,
+		Protocol:      "tcp",
+		HostIP:        "0.0.0.0",
+		HostPort:      "32768",
+	}}, c.Ports)
+
+	assert.Equal(t, map[string]string{
+		"PORT":         "5000",
+		"DATABASE_URL": redactedEnvValue,
+		"api_key":      redactedEnvValue,
+		"EMPTY":        "",
+	}, c.Env)
+	assert.Equal(t, "api", c.Labels["com.dokku.app-name"])
+}
+
+func TestParseContainerInspectErrors(t *testing.T) {
+	_, err := ParseContainerInspect("no json here")
+	assert.Error(t, err)
+	_, err = ParseContainerInspect("[{\"Id\": 1}]")
+	assert.Error(t, err)
+	_, err = ParseContainerInspect(`[{"NetworkSettings": {"Ports": {"http/tcp": []}}}]`)
+	assert.Error(t, err)
+
+	containers, err := ParseContainerInspect("[]")
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+}
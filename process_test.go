@@ -3,6 +3,8 @@ package dokku
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -23,7 +25,7 @@ func (s *processManagerTestSuite) TestGetProcessInfo() {
 	err = s.Client.CreateApp(testAppName)
 	r.NoError(err, "failed to create app")
 
-	err = s.Client.GetProcessInfo(testAppName)
+	_, err = s.Client.GetProcessInfo(testAppName)
 	r.ErrorIs(err, AppNotDeployedError, "did not detect app not being deployed")
 }
 
@@ -72,3 +74,90 @@ func (s *processManagerTestSuite) TestSetProcessScale() {
 	_, err = s.Client.SetAppProcessScale(testAppName, "web", 2, true)
 	r.NoError(err, "failed to set app scale")
 }
+
+const testAppProcessReport = `=====> api ps information
+       Deployed:                      true
+       Processes:                     3
+       Ps computed stop timeout seconds: 30
+       Ps global stop timeout seconds: 10
+       Ps stop timeout seconds:       30
+       Running:                       true
+       Status web 1:                  running (CID: 03ea8977f37)
+       Status web 2:                  exited (CID: 8ab3dd9a12c)
+       Status release-worker 1:       running (CID: 1ffe2ac0b2e)`
+
+const testProcessReport = testAppProcessReport + `
+=====> blog ps information
+       Deployed:                      true
+       Processes:                     1
+       Running:                       true
+       Status web 1:                  running (CID: 55a7c2f1e09)`
+
+func TestGetAppProcessReportStatuses(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report api", testAppProcessReport)
+
+	report, err := client.GetAppProcessReport("api")
+	require.NoError(t, err)
+	assert.Equal(t, 30, report.StopTimeoutSeconds)
+	assert.Equal(t, 10, report.GlobalStopTimeoutSeconds)
+	assert.Equal(t, []ProcessStatus{
+		{Process: "release-worker", Index: 1, Status: "running", ContainerID: "1ffe2ac0b2e"},
+		{Process: "web", Index: 1, Status: "running", ContainerID: "03ea8977f37"},
+		{Process: "web", Index: 2, Status: "exited", ContainerID: "8ab3dd9a12c"},
+	}, report.Statuses)
+}
+
+func TestGetAllProcessReportStatuses(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report", testProcessReport)
+
+	report, err := client.GetAllProcessReport()
+	require.NoError(t, err)
+	require.Contains(t, report, "api")
+	require.Contains(t, report, "blog")
+	assert.Len(t, report["api"].Statuses, 3)
+	assert.Equal(t, []ProcessStatus{
+		{Process: "web", Index: 1, Status: "running", ContainerID: "55a7c2f1e09"},
+	}, report["blog"].Statuses)
+}
+
+func TestSetProcessProperties(t *testing.T) {
+	client, fe := newFakeClient()
+
+	require.NoError(t, client.SetAppStopTimeoutSeconds("api", 60))
+	require.NoError(t, client.SetGlobalStopTimeoutSeconds(15))
+	require.NoError(t, client.ClearAppProcessProperty("api", ProcessPropertyProcfilePath))
+	assert.Error(t, client.SetAppStopTimeoutSeconds("api", -1))
+
+	assert.Equal(t, []string{
+		"ps:set api stop-timeout-seconds 60",
+		"ps:set --global stop-timeout-seconds 15",
+		"ps:set api procfile-path",
+	}, fe.commands)
+}
+
+func TestRestoreAndRetireApps(t *testing.T) {
+	client, fe := newFakeClient()
+
+	for _, run := range []func() (*CommandOutputStream, error){
+		client.RestoreApps,
+		client.RetireApps,
+		func() (*CommandOutputStream, error) { return client.RestoreApp("api") },
+	} {
+		stream, err := run()
+		require.NoError(t, err)
+		require.NoError(t, stream.Wait())
+	}
+	assert.Equal(t, []string{"ps:restore", "ps:retire", "ps:restore api"}, fe.commands)
+}
+
+func TestGetAppProcessReportMixed(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report api", "=====> api ps information\n       Deployed:   true\n       Running:    mixed")
+
+	report, err := client.GetAppProcessReport("api")
+	require.NoError(t, err)
+	assert.False(t, report.Running)
+	assert.Equal(t, ProcessRunningMixed, report.RunningState)
+}
@@ -0,0 +1,332 @@
+package dokku
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type releaseManager interface {
+	ListAppReleases(appName string) ([]AppRelease, error)
+	RecordAppRelease(appName string) (*AppRelease, error)
+	RollbackApp(appName string, release string) (*CommandOutputStream, error)
+}
+
+// AppRelease is an image dokku has kept for an app, with the commit it was
+// built from. dokku only keeps the image of the current deploy, so images
+// are kept and linked to their commit by RecordAppRelease, which tags the
+// current image with its git SHA. SHA is empty for images tagged otherwise.
+type AppRelease struct {
+	SHA        string   `json:"sha,omitempty"`
+	Repository string   `json:"repository"`
+	Tags       []string `json:"tags"`
+	ImageID    string   `json:"image_id"`
+	Created    string   `json:"created"`
+	Size       string   `json:"size"`
+
+	// set on the release currently deployed
+	Current bool `json:"current"`
+}
+
+// Image is the image reference of the release, preferring its release tag.
+func (r AppRelease) Image() string {
+	tag := r.Tags[0]
+	for _, t := range r.Tags {
+		if t == releaseImageTag(r.SHA) {
+			tag = t
+		}
+	}
+	return fmt.Sprintf("%s:%s", r.Repository, tag)
+}
+
+// imageTag is a row of the tags command.
+type imageTag struct {
+	Repository string
+	Tag        string
+	ImageID    string
+	Created    string
+	Size       string
+}
+
+const (
+	tagsListCmd   = "tags %s"
+	tagsCreateCmd = "tags:create %s %s"
+
+	latestImageTag   = "latest"
+	releaseTagPrefix = "git-"
+	tagsHeaderPrefix = "=====> "
+
+	// shortest SHA prefix accepted by RollbackApp
+	minReleaseSHALength = 7
+)
+
+var (
+	ReleaseNotFoundError   = errors.New("release does not exist")
+	NoDeployedReleaseError = errors.New("app has no deployed commit")
+)
+
+func releaseImageTag(sha string) string {
+	return releaseTagPrefix + sha
+}
+
+func parseImageTags(output string) ([]imageTag, error) {
+	var header string
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, tagsHeaderPrefix) || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if header == "" {
+			header = line
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if header == "" {
+		return []imageTag{}, nil
+	}
+
+	columns := []string{"REPOSITORY", "TAG", "IMAGE ID", "CREATED", "SIZE"}
+	indexes := make([]int, len(columns))
+	for i, col := range columns {
+		indexes[i] = strings.Index(header, col)
+		if indexes[i] < 0 || (i > 0 && indexes[i] < indexes[i-1]) {
+			return nil, fmt.Errorf("invalid image tags header '%s'", header)
+		}
+	}
+
+	column := func(line string, i int) string {
+		if indexes[i] >= len(line) {
+			return ""
+		}
+		end := len(line)
+		if i+1 < len(indexes) && indexes[i+1] < end {
+			end = indexes[i+1]
+		}
+		return strings.TrimSpace(line[indexes[i]:end])
+	}
+
+	tags := make([]imageTag, 0, len(lines))
+	for _, line := range lines {
+		tag := imageTag{
+			Repository: column(line, 0),
+			Tag:        column(line, 1),
+			ImageID:    column(line, 2),
+			Created:    column(line, 3),
+			Size:       column(line, 4),
+		}
+		if tag.Repository == "" || tag.Tag == "" {
+			return nil, fmt.Errorf("error parsing image tags line '%s'", line)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// ListAppReleases lists the images dokku has kept for the app, newest
+// first, with one release per image.
+func (c *BaseClient) ListAppReleases(appName string) ([]AppRelease, error) {
+	cmd := fmt.Sprintf(tagsListCmd, appName)
+	out, err := c.Exec(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := parseImageTags(out)
+	if err != nil {
+		return nil, err
+	}
+
+	gitReport, err := c.GitGetAppReport(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	return groupReleases(tags, gitReport.SHA), nil
+}
+
+// groupReleases merges the tags of each image into a release. The SHA of the
+// current release is the one git:report shows, others come from their
+// release tags.
+func groupReleases(tags []imageTag, currentSHA string) []AppRelease {
+	releases := []AppRelease{}
+	byImageID := map[string]int{}
+	for _, tag := range tags {
+		i, ok := byImageID[tag.ImageID]
+		if !ok {
+			i = len(releases)
+			byImageID[tag.ImageID] = i
+			releases = append(releases, AppRelease{
+				Repository: tag.Repository,
+				ImageID:    tag.ImageID,
+				Created:    tag.Created,
+				Size:       tag.Size,
+			})
+		}
+		release := &releases[i]
+		release.Tags = append(release.Tags, tag.Tag)
+		if tag.Tag == latestImageTag {
+			release.Current = true
+		}
+		if sha := strings.TrimPrefix(tag.Tag, releaseTagPrefix); sha != tag.Tag && release.SHA == "" {
+			release.SHA = sha
+		}
+	}
+	for i := range releases {
+		if releases[i].Current && currentSHA != "" {
+			releases[i].SHA = currentSHA
+		}
+	}
+	return releases
+}
+
+// RecordAppRelease tags the current image of the app with its git SHA, so it
+// is kept and listed as a release after later deploys. Call it after each
+// deploy to build up the release history.
+func (c *BaseClient) RecordAppRelease(appName string) (*AppRelease, error) {
+	releases, err := c.ListAppReleases(appName)
+	if err != nil {
+		return nil, err
+	}
+	return c.tagCurrentRelease(appName, releases)
+}
+
+func (c *BaseClient) tagCurrentRelease(appName string, releases []AppRelease) (*AppRelease, error) {
+	for i, release := range releases {
+		if !release.Current {
+			continue
+		}
+		if release.SHA == "" {
+			return nil, NoDeployedReleaseError
+		}
+		tag := releaseImageTag(release.SHA)
+		for _, t := range release.Tags {
+			if t == tag {
+				return &releases[i], nil
+			}
+		}
+		cmd := fmt.Sprintf(tagsCreateCmd, appName, tag)
+		if _, err := c.Exec(cmd); err != nil {
+			return nil, fmt.Errorf("failed to tag release: %w", err)
+		}
+		releases[i].Tags = append(releases[i].Tags, tag)
+		return &releases[i], nil
+	}
+	return nil, NoDeployedReleaseError
+}
+
+// findRelease finds a release by its SHA or a prefix of it, one of its tags
+// or its image ID.
+func findRelease(releases []AppRelease, release string) *AppRelease {
+	for i, r := range releases {
+		if r.ImageID == release {
+			return &releases[i]
+		}
+		if r.SHA != "" && len(release) >= minReleaseSHALength && strings.HasPrefix(r.SHA, release) {
+			return &releases[i]
+		}
+		for _, tag := range r.Tags {
+			if tag == release {
+				return &releases[i]
+			}
+		}
+	}
+	return nil
+}
+
+// RollbackApp redeploys a previous release of the app, identified by its
+// SHA, a tag or its image ID. The current release is recorded first, so it
+// can be rolled forward to again. The returned stream finishes once the
+// deploy is done and the app's registry settings have been restored.
+func (c *BaseClient) RollbackApp(appName string, release string) (*CommandOutputStream, error) {
+	releases, err := c.ListAppReleases(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	target := findRelease(releases, release)
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s", ReleaseNotFoundError, release)
+	}
+
+	if _, err := c.tagCurrentRelease(appName, releases); err != nil && !errors.Is(err, NoDeployedReleaseError) {
+		return nil, err
+	}
+
+	registry, err := c.GetAppDockerRegistryReport(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	deploy, err := c.GitCreateFromImage(appName, target.Image(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	stream := &CommandOutputStream{
+		Stdout: stdoutReader,
+		Stderr: stderrReader,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(stream.done)
+
+		var wg sync.WaitGroup
+		pipes := map[*io.PipeWriter]io.Reader{
+			stdoutWriter: deploy.Stdout,
+			stderrWriter: deploy.Stderr,
+		}
+		for w, r := range pipes {
+			wg.Add(1)
+			go func(w *io.PipeWriter, r io.Reader) {
+				defer wg.Done()
+				_, err := io.Copy(w, r)
+				_ = w.CloseWithError(err)
+			}(w, r)
+		}
+		wg.Wait()
+
+		stream.Error = deploy.Wait()
+		if stream.Error == nil {
+			stream.Error = c.restoreRegistrySettings(appName, registry)
+		}
+	}()
+
+	return stream, nil
+}
+
+// git:from-image may point the app at the image it was given and bump its
+// tag, so put the app's own image repo and tag back once the rollback has
+// been deployed.
+func (c *BaseClient) restoreRegistrySettings(appName string, before *AppDockerRegistryReport) error {
+	after, err := c.GetAppDockerRegistryReport(appName)
+	if err != nil {
+		return err
+	}
+	properties := []struct {
+		property      DockerRegistryProperty
+		before, after string
+	}{
+		{DockerRegistryPropertyImageRepo, before.ImageRepo, after.ImageRepo},
+		{DockerRegistryPropertyTagVersion, before.TagVersion, after.TagVersion},
+	}
+	for _, p := range properties {
+		if p.after == p.before {
+			continue
+		}
+		if p.before == "" {
+			err = c.ClearAppDockerRegistryProperty(appName, p.property)
+		} else {
+			err = c.SetAppDockerRegistryProperty(appName, p.property, p.before)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore registry %s: %w", p.property, err)
+		}
+	}
+	return nil
+}
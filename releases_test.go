@@ -0,0 +1,110 @@
+package dokku
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testImageTagsOutput = `=====> Image tags for dokku/api
+REPOSITORY          TAG                 IMAGE ID            CREATED              SIZE
+dokku/api           latest              936a42f25901        About a minute ago   1.025 GB
+dokku/api           v2                  936a42f25901        About a minute ago   1.025 GB
+dokku/api           git-0123456789ab    4fa2c3b5e1d0        2 days ago           1.01 GB
+dokku/api           v1                  4fa2c3b5e1d0        2 days ago           1.01 GB
+dokku/api           nightly             1b2c3d4e5f60        5 days ago           1.0 GB`
+	testGitShaReport = "=====> api git information\n       Git sha:                       fedcba9876543210"
+)
+
+func TestParseImageTags(t *testing.T) {
+	tags, err := parseImageTags(testImageTagsOutput)
+	require.NoError(t, err)
+	require.Len(t, tags, 5)
+	assert.Equal(t, imageTag{
+		Repository: "dokku/api",
+		Tag:        "v1",
+		ImageID:    "4fa2c3b5e1d0",
+		Created:    "2 days ago",
+		Size:       "1.01 GB",
+	}, tags[3])
+
+	empty, err := parseImageTags("=====> Image tags for dokku/api")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	_, err = parseImageTags("garbage header\ndokku/api latest")
+	assert.Error(t, err)
+}
+
+func TestListAppReleases(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("tags api", testImageTagsOutput)
+	fe.on("git:report api", testGitShaReport)
+
+	releases, err := client.ListAppReleases("api")
+	require.NoError(t, err)
+	require.Len(t, releases, 3)
+
+	assert.True(t, releases[0].Current)
+	assert.Equal(t, "fedcba9876543210", releases[0].SHA)
+	assert.Equal(t, []string{"latest", "v2"}, releases[0].Tags)
+
+	assert.False(t, releases[1].Current)
+	assert.Equal(t, "0123456789ab", releases[1].SHA)
+	assert.Equal(t, "dokku/api:git-0123456789ab", releases[1].Image())
+
+	assert.Empty(t, releases[2].SHA)
+	assert.Equal(t, "dokku/api:nightly", releases[2].Image())
+}
+
+func TestRecordAppRelease(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("tags api", testImageTagsOutput)
+	fe.on("git:report api", testGitShaReport)
+
+	release, err := client.RecordAppRelease("api")
+	require.NoError(t, err)
+	assert.Equal(t, "fedcba9876543210", release.SHA)
+	assert.Equal(t, "tags:create api git-fedcba9876543210", fe.commands[len(fe.commands)-1])
+
+	fe.on("git:report api", "=====> api git information\n       Git sha:")
+	_, err = client.RecordAppRelease("api")
+	assert.ErrorIs(t, err, NoDeployedReleaseError)
+}
+
+func TestRollbackApp(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("tags api", testImageTagsOutput)
+	fe.on("git:report api", testGitShaReport)
+	fe.on("registry:report api", `=====> api registry information
+       Registry image repo:
+       Registry tag version:          4`)
+
+	_, err := client.RollbackApp("api", "v0")
+	assert.ErrorIs(t, err, ReleaseNotFoundError)
+	_, err = client.RollbackApp("api", "0123")
+	assert.ErrorIs(t, err, ReleaseNotFoundError, "SHA prefixes are at least 7 characters")
+
+	fe.on("git:from-image api dokku/api:git-0123456789ab", "-----> Deploying api via the docker-local scheduler...")
+	fe.commands = nil
+	stream, err := client.RollbackApp("api", "0123456")
+	require.NoError(t, err)
+	// the current release is kept to roll forward to
+	assert.Contains(t, fe.commands, "tags:create api git-fedcba9876543210")
+	// the deploy points the app at the rolled back image and bumps its tag
+	fe.on("registry:report api", `=====> api registry information
+       Registry image repo:           dokku/api
+       Registry tag version:          5`)
+	out, err := io.ReadAll(stream.Stdout)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Deploying api")
+	require.NoError(t, stream.Wait())
+	assert.Equal(t, []string{
+		"registry:report api",
+		"registry:set api image-repo",
+		"registry:set api tag-version 4",
+	}, fe.commands[len(fe.commands)-3:])
+}
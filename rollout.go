@@ -0,0 +1,315 @@
+package dokku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RolloutOperation string
+
+const (
+	RolloutRestart = RolloutOperation("restart")
+	RolloutRebuild = RolloutOperation("rebuild")
+)
+
+type RolloutOptions struct {
+	// optional, defaults to RolloutRestart
+	Operation RolloutOperation
+	// optional, defaults to all deployed apps
+	Apps []string
+	// optional, defaults to 1. The apps of a batch are rolled concurrently.
+	BatchSize int
+	// optional, maps an app to the apps which must be rolled before it.
+	// Apps outside of the rollout are ignored.
+	DependsOn map[string][]string
+	// optional, defaults to 1. The rollout stops after this many apps failed.
+	MaxFailures int
+	// optional, how long to wait for each app to be running again, defaults
+	// to 5 minutes
+	WaitTimeout time.Duration
+	Wait        *WaitOptions
+	Parallelism *ParallelismOptions
+	// optional, receives the output of all apps
+	Output io.Writer
+	// optional, called when an app is done
+	OnResult func(result RolloutAppResult)
+}
+
+type RolloutAppStatus string
+
+const (
+	RolloutSucceeded = RolloutAppStatus("succeeded")
+	RolloutFailed    = RolloutAppStatus("failed")
+	RolloutSkipped   = RolloutAppStatus("skipped")
+)
+
+type RolloutAppResult struct {
+	AppName  string
+	Batch    int
+	Status   RolloutAppStatus
+	Reason   string
+	Duration time.Duration
+	Err      error
+}
+
+type RolloutReport struct {
+	Results []RolloutAppResult
+	// the rollout stopped early because the failure budget was used up or
+	// the context was done
+	Aborted bool
+}
+
+// RolloutError is returned when at least one app of a rollout failed.
+type RolloutError struct {
+	Failed  []string
+	Aborted bool
+}
+
+func (e *RolloutError) Error() string {
+	msg := fmt.Sprintf("rollout failed for apps: %s", strings.Join(e.Failed, ", "))
+	if e.Aborted {
+		msg += " (aborted)"
+	}
+	return msg
+}
+
+const defaultRolloutWaitTimeout = 5 * time.Minute
+
+func (r *RolloutReport) Failed() []string {
+	return r.appsWithStatus(RolloutFailed)
+}
+
+func (r *RolloutReport) Skipped() []string {
+	return r.appsWithStatus(RolloutSkipped)
+}
+
+func (r *RolloutReport) appsWithStatus(status RolloutAppStatus) []string {
+	apps := []string{}
+	for _, result := range r.Results {
+		if result.Status == status {
+			apps = append(apps, result.AppName)
+		}
+	}
+	return apps
+}
+
+// rolloutBatches orders the apps so every app comes in a later batch than
+// its dependencies, and splits them into batches of at most size apps.
+func rolloutBatches(apps []string, dependsOn map[string][]string, size int) ([][]string, error) {
+	inRollout := map[string]bool{}
+	for _, app := range apps {
+		inRollout[app] = true
+	}
+
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, app := range apps {
+		pending[app] = 0
+		for _, dep := range dependsOn[app] {
+			if !inRollout[dep] || dep == app {
+				continue
+			}
+			pending[app]++
+			dependents[dep] = append(dependents[dep], app)
+		}
+	}
+
+	var batches [][]string
+	level := []string{}
+	for _, app := range apps {
+		if pending[app] == 0 {
+			level = append(level, app)
+		}
+	}
+	ordered := 0
+	for len(level) > 0 {
+		sort.Strings(level)
+		ordered += len(level)
+		for start := 0; start < len(level); start += size {
+			end := start + size
+			if end > len(level) {
+				end = len(level)
+			}
+			batches = append(batches, level[start:end])
+		}
+
+		next := []string{}
+		for _, app := range level {
+			for _, dependent := range dependents[app] {
+				pending[dependent]--
+				if pending[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		level = next
+	}
+
+	if ordered < len(apps) {
+		cyclic := []string{}
+		for _, app := range apps {
+			if pending[app] > 0 {
+				cyclic = append(cyclic, app)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("dependency cycle between apps: %s", strings.Join(cyclic, ", "))
+	}
+	return batches, nil
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// RolloutApps restarts or rebuilds apps batch by batch. After each batch it
+// waits for the apps to be running before starting the next one, and stops
+// once MaxFailures apps failed. Apps depending on a failed app are skipped.
+// The report is returned even if the rollout failed.
+func (c *BaseClient) RolloutApps(ctx context.Context, options *RolloutOptions) (*RolloutReport, error) {
+	opts := RolloutOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Operation == "" {
+		opts.Operation = RolloutRestart
+	}
+	if opts.Operation != RolloutRestart && opts.Operation != RolloutRebuild {
+		return nil, fmt.Errorf("invalid rollout operation '%s'", opts.Operation)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = 1
+	}
+	if opts.WaitTimeout <= 0 {
+		opts.WaitTimeout = defaultRolloutWaitTimeout
+	}
+	if opts.Output != nil {
+		opts.Output = &syncWriter{w: opts.Output}
+	}
+
+	report := &RolloutReport{Results: []RolloutAppResult{}}
+	apps := opts.Apps
+	if apps == nil {
+		processReports, err := c.GetAllProcessReport()
+		if err != nil && !errors.Is(err, NoDeployedAppsError) {
+			return nil, err
+		}
+		apps = []string{}
+		for _, app := range sortedKeys(processReports) {
+			if processReports[app].Deployed {
+				apps = append(apps, app)
+			}
+		}
+	}
+
+	batches, err := rolloutBatches(apps, opts.DependsOn, opts.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := map[string]bool{}
+	failures := 0
+	for i, batch := range batches {
+		if failures >= opts.MaxFailures || ctx.Err() != nil {
+			report.Aborted = true
+			for _, app := range batch {
+				report.Results = append(report.Results, opts.notify(RolloutAppResult{
+					AppName: app, Batch: i, Status: RolloutSkipped, Reason: "rollout aborted",
+				}))
+			}
+			continue
+		}
+
+		results := make([]RolloutAppResult, len(batch))
+		var wg sync.WaitGroup
+		for j, app := range batch {
+			results[j] = RolloutAppResult{AppName: app, Batch: i}
+			if dep := failedDependency(app, opts.DependsOn, failed); dep != "" {
+				results[j].Status = RolloutSkipped
+				results[j].Reason = fmt.Sprintf("dependency '%s' failed", dep)
+				continue
+			}
+			wg.Add(1)
+			go func(result *RolloutAppResult) {
+				defer wg.Done()
+				start := time.Now()
+				result.Err = c.rolloutApp(ctx, result.AppName, &opts)
+				result.Duration = time.Since(start)
+				result.Status = RolloutSucceeded
+				if result.Err != nil {
+					result.Status = RolloutFailed
+					result.Reason = result.Err.Error()
+				}
+			}(&results[j])
+		}
+		wg.Wait()
+
+		for _, result := range results {
+			if result.Status != RolloutSucceeded {
+				failed[result.AppName] = true
+			}
+			if result.Status == RolloutFailed {
+				failures++
+			}
+			report.Results = append(report.Results, opts.notify(result))
+		}
+	}
+
+	if failures > 0 || report.Aborted {
+		return report, &RolloutError{Failed: report.Failed(), Aborted: report.Aborted}
+	}
+	return report, nil
+}
+
+func (opts *RolloutOptions) notify(result RolloutAppResult) RolloutAppResult {
+	if opts.OnResult != nil {
+		opts.OnResult(result)
+	}
+	return result
+}
+
+func failedDependency(app string, dependsOn map[string][]string, failed map[string]bool) string {
+	for _, dep := range dependsOn[app] {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+func (c *BaseClient) rolloutApp(ctx context.Context, appName string, opts *RolloutOptions) error {
+	var stream *CommandOutputStream
+	var err error
+	if opts.Operation == RolloutRebuild {
+		stream, err = c.RebuildApp(appName, opts.Parallelism)
+	} else {
+		stream, err = c.RestartApp(appName, opts.Parallelism)
+	}
+	if err != nil {
+		return err
+	}
+	if err := copyStreamOutput(stream, opts.Output); err != nil {
+		return fmt.Errorf("failed to %s: %w", opts.Operation, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, opts.WaitTimeout)
+	defer cancel()
+	_, err = c.WaitForAppRunning(waitCtx, appName, opts.Wait)
+	return err
+}
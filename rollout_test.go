@@ -0,0 +1,89 @@
+package dokku
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutBatches(t *testing.T) {
+	batches, err := rolloutBatches([]string{"web", "api", "db", "worker"}, map[string][]string{
+		"api":    {"db"},
+		"web":    {"api", "unknown"},
+		"worker": {"db"},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"db"}, {"api"}, {"worker"}, {"web"}}, batches)
+
+	batches, err = rolloutBatches([]string{"a", "b", "c"}, nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, batches)
+
+	_, err = rolloutBatches([]string{"a", "b", "c"}, map[string][]string{"a": {"b"}, "b": {"a"}}, 1)
+	assert.ErrorContains(t, err, "a, b")
+}
+
+func testRolloutOptions(apps ...string) *RolloutOptions {
+	return &RolloutOptions{
+		Apps:        apps,
+		WaitTimeout: 50 * time.Millisecond,
+		Wait:        testWaitOptions,
+	}
+}
+
+func TestRolloutApps(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report", "=====> api ps information\n       Deployed: true\n=====> db ps information\n       Deployed: true\n=====> new ps information\n       Deployed: false")
+	for _, app := range []string{"api", "db"} {
+		fe.on("ps:report "+app, "=====> "+app+" ps information\n       Deployed: true\n       Running: true")
+	}
+
+	options := testRolloutOptions()
+	options.Apps = nil
+	options.Operation = RolloutRebuild
+	options.DependsOn = map[string][]string{"api": {"db"}}
+	var notified []string
+	options.OnResult = func(result RolloutAppResult) {
+		notified = append(notified, result.AppName)
+	}
+
+	report, err := client.RolloutApps(context.Background(), options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "api"}, notified)
+	assert.Empty(t, report.Failed())
+	assert.False(t, report.Aborted)
+	assert.Contains(t, fe.commands, "ps:rebuild --parallel 1 db")
+	assert.Contains(t, fe.commands, "ps:rebuild --parallel 1 api")
+	assert.NotContains(t, fe.commands, "ps:rebuild --parallel 1 new")
+}
+
+func TestRolloutAppsFailureBudget(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.fail("ps:restart --parallel 1 a", errors.New("boom"))
+	fe.on("ps:report b", "=====> b ps information\n       Deployed: true\n       Running: false")
+	fe.on("ps:report d", "=====> d ps information\n       Deployed: true\n       Running: true")
+
+	options := testRolloutOptions("a", "b", "c", "d")
+	options.MaxFailures = 2
+	options.DependsOn = map[string][]string{"c": {"a"}}
+
+	report, err := client.RolloutApps(context.Background(), options)
+	var rolloutErr *RolloutError
+	require.ErrorAs(t, err, &rolloutErr)
+	assert.True(t, rolloutErr.Aborted)
+	assert.Equal(t, []string{"a", "b"}, rolloutErr.Failed)
+	assert.Equal(t, []string{"d", "c"}, report.Skipped())
+
+	statuses := map[string]RolloutAppStatus{}
+	for _, result := range report.Results {
+		statuses[result.AppName] = result.Status
+	}
+	assert.Equal(t, map[string]RolloutAppStatus{
+		"a": RolloutFailed, "b": RolloutFailed, "c": RolloutSkipped, "d": RolloutSkipped,
+	}, statuses)
+	assert.NotContains(t, fe.commands, "ps:restart --parallel 1 d")
+}
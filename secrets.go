@@ -0,0 +1,330 @@
+package dokku
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+type secretsManager interface {
+	ApplySecrets(appName string, file *SecretsFile, keyring *SecretsKeyring, restart bool) ([]string, error)
+	VerifySecrets(appName string, file *SecretsFile, keyring *SecretsKeyring) (*SecretsVerification, error)
+}
+
+const SecretsFileVersion = 1
+
+var (
+	UnsupportedSecretsVersionError = errors.New("unsupported secrets file version")
+	SecretsKeyNotFoundError        = errors.New("no key in the keyring can decrypt the secrets file")
+	InvalidSecretsKeyError         = errors.New("invalid secrets key")
+)
+
+const redactedSecret = "<redacted>"
+
+// SecretsKey is a curve25519 key pair. Secrets are sealed anonymously to
+// the public key with NaCl box, so only the private key can open them.
+type SecretsKey struct {
+	PublicKey  *[32]byte
+	PrivateKey *[32]byte
+}
+
+func GenerateSecretsKey() (*SecretsKey, error) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretsKey{PublicKey: public, PrivateKey: private}, nil
+}
+
+// Recipient returns the encoded public key, which secrets are encrypted to.
+func (k *SecretsKey) Recipient() string {
+	return b64.StdEncoding.EncodeToString(k.PublicKey[:])
+}
+
+func decodeKey(encoded string) (*[32]byte, error) {
+	raw, err := b64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 32 {
+		return nil, InvalidSecretsKeyError
+	}
+	var key [32]byte
+	copy(key[:], raw)
+	return &key, nil
+}
+
+// SecretsKeyring holds the private keys used to decrypt secrets files.
+type SecretsKeyring struct {
+	keys map[string]*SecretsKey
+}
+
+func NewSecretsKeyring(keys ...*SecretsKey) *SecretsKeyring {
+	keyring := &SecretsKeyring{keys: map[string]*SecretsKey{}}
+	for _, key := range keys {
+		keyring.keys[key.Recipient()] = key
+	}
+	return keyring
+}
+
+func LoadSecretsKeyring(path string) (*SecretsKeyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSecretsKeyring(f)
+}
+
+// ParseSecretsKeyring reads a keyring file, which has an encoded private key
+// per line. Blank lines and lines starting with '#' are ignored.
+func ParseSecretsKeyring(r io.Reader) (*SecretsKeyring, error) {
+	keyring := NewSecretsKeyring()
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		private, err := decodeKey(text)
+		if err != nil {
+			return nil, fmt.Errorf("keyring line %d: %w", line, err)
+		}
+		public, err := curve25519.X25519(private[:], curve25519.Basepoint)
+		if err != nil {
+			return nil, fmt.Errorf("keyring line %d: %w", line, InvalidSecretsKeyError)
+		}
+		key := &SecretsKey{PublicKey: (*[32]byte)(public), PrivateKey: private}
+		keyring.keys[key.Recipient()] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+func (k *SecretsKeyring) Encode(w io.Writer) error {
+	recipients := sortedKeys(k.keys)
+	for _, recipient := range recipients {
+		private := b64.StdEncoding.EncodeToString(k.keys[recipient].PrivateKey[:])
+		if _, err := fmt.Fprintf(w, "# recipient %s\n%s\n", recipient, private); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecretsFile holds config values encrypted to a recipient, so it can be
+// committed. Each value is sealed separately, so changes show up per key.
+type SecretsFile struct {
+	Version   int               `json:"version"`
+	Recipient string            `json:"recipient"`
+	Secrets   map[string]string `json:"secrets"`
+}
+
+// Secrets are decrypted config values. Printing them with the fmt package
+// only shows their keys.
+type Secrets map[string]string
+
+func (s Secrets) String() string {
+	keys := sortedKeys(s)
+	for i, key := range keys {
+		keys[i] = key + ":" + redactedSecret
+	}
+	return "map[" + strings.Join(keys, " ") + "]"
+}
+
+func (s Secrets) GoString() string {
+	return "dokku.Secrets" + s.String()
+}
+
+func NewSecretsFile(recipient string) (*SecretsFile, error) {
+	if _, err := decodeKey(recipient); err != nil {
+		return nil, err
+	}
+	return &SecretsFile{
+		Version:   SecretsFileVersion,
+		Recipient: recipient,
+		Secrets:   map[string]string{},
+	}, nil
+}
+
+func EncryptSecrets(values map[string]string, recipient string) (*SecretsFile, error) {
+	file, err := NewSecretsFile(recipient)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		if err := file.Set(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func LoadSecretsFile(path string) (*SecretsFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeSecretsFile(f)
+}
+
+func DecodeSecretsFile(r io.Reader) (*SecretsFile, error) {
+	var file SecretsFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets file: %w", err)
+	}
+	if file.Version != SecretsFileVersion {
+		return nil, fmt.Errorf("%w: %d", UnsupportedSecretsVersionError, file.Version)
+	}
+	if file.Secrets == nil {
+		file.Secrets = map[string]string{}
+	}
+	return &file, nil
+}
+
+func (f *SecretsFile) Encode(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// Set encrypts value and stores it under key.
+func (f *SecretsFile) Set(key string, value string) error {
+	if err := validateConfigKey(key); err != nil {
+		return err
+	}
+	recipient, err := decodeKey(f.Recipient)
+	if err != nil {
+		return err
+	}
+	sealed, err := box.SealAnonymous(nil, []byte(value), recipient, rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret '%s': %w", key, err)
+	}
+	f.Secrets[key] = b64.StdEncoding.EncodeToString(sealed)
+	return nil
+}
+
+func (f *SecretsFile) Decrypt(keyring *SecretsKeyring) (Secrets, error) {
+	key, ok := keyring.keys[f.Recipient]
+	if !ok {
+		return nil, SecretsKeyNotFoundError
+	}
+
+	secrets := Secrets{}
+	for name, encoded := range f.Secrets {
+		sealed, err := b64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode secret '%s'", name)
+		}
+		value, ok := box.OpenAnonymous(nil, sealed, key.PublicKey, key.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("failed to decrypt secret '%s'", name)
+		}
+		secrets[name] = string(value)
+	}
+	return secrets, nil
+}
+
+// redactSecretsError strips secret values from err, which may contain the
+// output of the failed command. The original error is not wrapped, so it
+// can't be printed by accident.
+func redactSecretsError(err error, secrets Secrets) error {
+	msg := err.Error()
+	for _, value := range secrets {
+		if value != "" {
+			// config:set receives values base64 encoded
+			msg = strings.ReplaceAll(msg, b64.StdEncoding.EncodeToString([]byte(value)), redactedSecret)
+			msg = strings.ReplaceAll(msg, value, redactedSecret)
+		}
+	}
+	return errors.New(msg)
+}
+
+// ApplySecrets decrypts the secrets file in memory and sets the values that
+// differ from the app config, returning the sorted keys that were changed.
+func (c *BaseClient) ApplySecrets(appName string, file *SecretsFile, keyring *SecretsKeyring, restart bool) ([]string, error) {
+	secrets, err := file.Decrypt(keyring)
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]string{}
+	for key, value := range secrets {
+		if existing, ok := current[key]; ok && subtle.ConstantTimeCompare([]byte(existing), []byte(value)) == 1 {
+			continue
+		}
+		changes[key] = value
+	}
+	changed := sortedKeys(changes)
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	if err := c.SetAppConfigValues(appName, changes, restart); err != nil {
+		return nil, fmt.Errorf("failed to set secrets: %w", redactSecretsError(err, secrets))
+	}
+	return changed, nil
+}
+
+// SecretsVerification lists the keys of a secrets file by how they compare
+// to the app config. It never holds values.
+type SecretsVerification struct {
+	Matching   []string
+	Mismatched []string
+	Missing    []string
+}
+
+func (v *SecretsVerification) OK() bool {
+	return len(v.Mismatched) == 0 && len(v.Missing) == 0
+}
+
+// VerifySecrets checks the app config holds the values of the secrets file.
+func (c *BaseClient) VerifySecrets(appName string, file *SecretsFile, keyring *SecretsKeyring) (*SecretsVerification, error) {
+	secrets, err := file.Decrypt(keyring)
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.GetAppConfigBundle(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &SecretsVerification{
+		Matching:   []string{},
+		Mismatched: []string{},
+		Missing:    []string{},
+	}
+	for key, value := range secrets {
+		existing, ok := current[key]
+		switch {
+		case !ok:
+			v.Missing = append(v.Missing, key)
+		case subtle.ConstantTimeCompare([]byte(existing), []byte(value)) == 1:
+			v.Matching = append(v.Matching, key)
+		default:
+			v.Mismatched = append(v.Mismatched, key)
+		}
+	}
+	sort.Strings(v.Matching)
+	sort.Strings(v.Mismatched)
+	sort.Strings(v.Missing)
+	return v, nil
+}
@@ -0,0 +1,81 @@
+package dokku
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSecrets(t *testing.T, values map[string]string) (*SecretsFile, *SecretsKeyring) {
+	key, err := GenerateSecretsKey()
+	require.NoError(t, err)
+	file, err := EncryptSecrets(values, key.Recipient())
+	require.NoError(t, err)
+	return file, NewSecretsKeyring(key)
+}
+
+func TestSecretsRoundTrip(t *testing.T) {
+	values := map[string]string{"API_TOKEN": "s3cr3t", "EMPTY": ""}
+	file, keyring := newTestSecrets(t, values)
+
+	var fileBuf, keyringBuf bytes.Buffer
+	require.NoError(t, file.Encode(&fileBuf))
+	require.NoError(t, keyring.Encode(&keyringBuf))
+	assert.NotContains(t, fileBuf.String(), "s3cr3t")
+
+	decoded, err := DecodeSecretsFile(&fileBuf)
+	require.NoError(t, err)
+	parsedKeyring, err := ParseSecretsKeyring(&keyringBuf)
+	require.NoError(t, err)
+
+	secrets, err := decoded.Decrypt(parsedKeyring)
+	require.NoError(t, err)
+	assert.Equal(t, Secrets(values), secrets)
+
+	_, err = decoded.Decrypt(NewSecretsKeyring())
+	assert.ErrorIs(t, err, SecretsKeyNotFoundError)
+
+	_, err = DecodeSecretsFile(strings.NewReader(`{"version": 2}`))
+	assert.ErrorIs(t, err, UnsupportedSecretsVersionError)
+}
+
+func TestSecretsRedacted(t *testing.T) {
+	secrets := Secrets{"API_TOKEN": "s3cr3t"}
+	for _, format := range []string{"%v", "%s", "%+v", "%#v"} {
+		assert.NotContains(t, fmt.Sprintf(format, secrets), "s3cr3t", format)
+	}
+
+	err := redactSecretsError(errors.New("dokku error: 'API_TOKEN: s3cr3t'"), secrets)
+	assert.Equal(t, "dokku error: 'API_TOKEN: <redacted>'", err.Error())
+}
+
+func TestApplySecrets(t *testing.T) {
+	file, keyring := newTestSecrets(t, map[string]string{"SAME": "1", "CHANGED": "2", "NEW": "3"})
+	client, fe := newFakeClient()
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{"SAME": "1", "CHANGED": "old"}))
+
+	changed, err := client.ApplySecrets("api", file, keyring, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CHANGED", "NEW"}, changed)
+	assert.Contains(t, fe.commands[len(fe.commands)-1], "config:set --no-restart --encoded api")
+	assert.NotContains(t, fe.commands[len(fe.commands)-1], "SAME")
+}
+
+func TestVerifySecrets(t *testing.T) {
+	file, keyring := newTestSecrets(t, map[string]string{"SAME": "1", "CHANGED": "2", "NEW": "3"})
+	client, fe := newFakeClient()
+	fe.on("config:bundle api", testConfigBundle(t, map[string]string{"SAME": "1", "CHANGED": "old"}))
+
+	v, err := client.VerifySecrets("api", file, keyring)
+	require.NoError(t, err)
+	assert.False(t, v.OK())
+	assert.Equal(t, []string{"SAME"}, v.Matching)
+	assert.Equal(t, []string{"CHANGED"}, v.Mismatched)
+	assert.Equal(t, []string{"NEW"}, v.Missing)
+	assert.Equal(t, []string{"config:bundle api"}, fe.commands)
+}
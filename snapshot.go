@@ -0,0 +1,337 @@
+package dokku
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/parkerdgabel/dokku-go/internal/reports"
+)
+
+type snapshotManager interface {
+	SnapshotServer() (*ServerSnapshot, error)
+}
+
+type ServerSnapshot struct {
+	TakenAt      time.Time `json:"taken_at"`
+	DokkuVersion string    `json:"dokku_version"`
+
+	GlobalConfig  map[string]string       `json:"global_config"`
+	GlobalDomains *GlobalDomainsReport    `json:"global_domains"`
+	Global        GlobalSettings          `json:"global"`
+	Plugins       map[string]PluginInfo   `json:"plugins"`
+	Networks      []string                `json:"networks"`
+	SSHKeys       []SSHKey                `json:"ssh_keys"`
+	Apps          map[string]*AppSnapshot `json:"apps"`
+}
+
+// GlobalSettings are the --global values of per-app properties, as shown in
+// the --global reports of each plugin.
+type GlobalSettings struct {
+	Builder         string `json:"builder" dokku:"Builder global selected"`
+	BuildDir        string `json:"build_dir" dokku:"Builder global build dir"`
+	GitDeployBranch string `json:"git_deploy_branch" dokku:"Git global deploy branch"`
+	ProxyType       string `json:"proxy_type" dokku:"Proxy global type"`
+	Scheduler       string `json:"scheduler" dokku:"Scheduler global selected"`
+	NginxHSTS       bool   `json:"nginx_hsts" dokku:"Nginx global hsts"`
+
+	LetsEncryptDnsProvider    string `json:"letsencrypt_dns_provider" dokku:"Letsencrypt global dns provider"`
+	LetsEncryptEmail          string `json:"letsencrypt_email" dokku:"Letsencrypt global email"`
+	LetsEncryptGracePeriod    int    `json:"letsencrypt_grace_period" dokku:"Letsencrypt global graceperiod"`
+	LetsEncryptLegoDockerArgs string `json:"letsencrypt_lego_docker_args" dokku:"Letsencrypt global lego docker args"`
+	LetsEncryptServer         string `json:"letsencrypt_server" dokku:"Letsencrypt global server"`
+}
+
+type AppSnapshot struct {
+	App           *AppReport               `json:"app"`
+	Builder       *AppBuilderReport        `json:"builder"`
+	Certs         *AppCertsReport          `json:"certs"`
+	Checks        *AppChecksReport         `json:"checks"`
+	DockerOptions *AppDockerOptionsReport  `json:"docker_options"`
+	Domains       *AppDomainsReport        `json:"domains"`
+	Git           *GitAppReport            `json:"git"`
+	LetsEncrypt   *LetsEncryptAppReport    `json:"letsencrypt,omitempty"`
+	Network       *AppNetworkReport        `json:"network"`
+	Nginx         *AppNginxReport          `json:"nginx"`
+	Process       *AppProcessReport        `json:"process"`
+	Registry      *AppDockerRegistryReport `json:"registry"`
+	Resources     *AppResourceReport       `json:"resources"`
+	Scheduler     *AppSchedulerReport      `json:"scheduler"`
+	Storage       *AppStorageReport        `json:"storage"`
+}
+
+type SnapshotChangeKind string
+
+const (
+	SnapshotChangeAdded   = SnapshotChangeKind("added")
+	SnapshotChangeRemoved = SnapshotChangeKind("removed")
+	SnapshotChangeChanged = SnapshotChangeKind("changed")
+)
+
+type SnapshotChange struct {
+	Path string             `json:"path"`
+	Kind SnapshotChangeKind `json:"kind"`
+	Old  string             `json:"old,omitempty"`
+	New  string             `json:"new,omitempty"`
+}
+
+const letsEncryptPluginName = "letsencrypt"
+
+// runtime state which changes without anyone touching the configuration
+var snapshotVolatilePaths = []string{
+	"taken_at",
+	"apps/*/app/CreatedAtTimestamp",
+	"apps/*/git/last_updated_at",
+	"apps/*/git/sha",
+	"apps/*/letsencrypt/expiration",
+	"apps/*/network/WebListeners",
+	"apps/*/nginx/LastVisitedAt",
+	"apps/*/process/deployed",
+	"apps/*/process/processes",
+	"apps/*/process/running",
+	"apps/*/process/statuses",
+	"apps/*/process/statuses/*",
+	"apps/*/process/statuses/*/*",
+}
+
+func (c *BaseClient) SnapshotServer() (*ServerSnapshot, error) {
+	snapshot := &ServerSnapshot{
+		TakenAt: time.Now().UTC(),
+		Plugins: map[string]PluginInfo{},
+		Apps:    map[string]*AppSnapshot{},
+	}
+
+	var err error
+	if snapshot.DokkuVersion, err = c.GetDokkuVersion(); err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+	if snapshot.GlobalConfig, err = c.GetGlobalConfig(); err != nil {
+		return nil, fmt.Errorf("failed to get global config: %w", err)
+	}
+	if snapshot.GlobalDomains, err = c.GetGlobalDomainsReport(); err != nil {
+		return nil, fmt.Errorf("failed to get global domains: %w", err)
+	}
+	sort.Strings(snapshot.GlobalDomains.Domains)
+
+	plugins, err := c.ListPlugins()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %w", err)
+	}
+	for _, plugin := range plugins {
+		snapshot.Plugins[plugin.Name] = plugin
+	}
+
+	if snapshot.Networks, err = c.ListNetworks(); err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	sort.Strings(snapshot.Networks)
+
+	if snapshot.SSHKeys, err = c.ListSSHKeys(); err != nil {
+		return nil, fmt.Errorf("failed to list ssh keys: %w", err)
+	}
+	sort.Slice(snapshot.SSHKeys, func(i, j int) bool {
+		return snapshot.SSHKeys[i].Fingerprint < snapshot.SSHKeys[j].Fingerprint
+	})
+
+	_, letsEncryptInstalled := snapshot.Plugins[letsEncryptPluginName]
+	if err := c.snapshotGlobalSettings(&snapshot.Global, letsEncryptInstalled); err != nil {
+		return nil, err
+	}
+	if err := c.snapshotApps(snapshot, letsEncryptInstalled); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (c *BaseClient) snapshotApps(snapshot *ServerSnapshot, letsEncryptInstalled bool) error {
+	appReports, err := c.GetAllAppReport()
+	if errors.Is(err, NoDeployedAppsError) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get app reports: %w", err)
+	}
+	for appName, report := range appReports {
+		snapshot.Apps[appName] = &AppSnapshot{App: report}
+	}
+
+	processReports, err := c.GetAllProcessReport()
+	if err != nil {
+		return fmt.Errorf("failed to get process reports: %w", err)
+	}
+	gitReports, err := c.GitGetReport()
+	if err != nil {
+		return fmt.Errorf("failed to get git reports: %w", err)
+	}
+	domainsReports, err := c.GetDomainsReport()
+	if err != nil {
+		return fmt.Errorf("failed to get domains reports: %w", err)
+	}
+	checksReports, err := c.GetDeployChecksReport()
+	if err != nil {
+		return fmt.Errorf("failed to get checks reports: %w", err)
+	}
+	resourceReports, err := c.GetResourceReport()
+	if err != nil {
+		return fmt.Errorf("failed to get resource reports: %w", err)
+	}
+	storageReports, err := c.GetStorageReport()
+	if err != nil {
+		return fmt.Errorf("failed to get storage reports: %w", err)
+	}
+	networkReports, err := c.GetNetworkReport()
+	if err != nil {
+		return fmt.Errorf("failed to get network reports: %w", err)
+	}
+	nginxReports, err := c.GetGlobalNginxReport()
+	if err != nil {
+		return fmt.Errorf("failed to get nginx reports: %w", err)
+	}
+	schedulerReports, err := c.GetSchedulerReport()
+	if err != nil {
+		return fmt.Errorf("failed to get scheduler reports: %w", err)
+	}
+	certsReports, err := c.GetCertsReport()
+	if err != nil {
+		return fmt.Errorf("failed to get certs reports: %w", err)
+	}
+	dockerOptionsReports, err := c.GetGlobalDockerOptionsReport()
+	if err != nil {
+		return fmt.Errorf("failed to get docker options reports: %w", err)
+	}
+	registryReports, err := c.GetDockerRegistryReport()
+	if err != nil {
+		return fmt.Errorf("failed to get registry reports: %w", err)
+	}
+
+	for appName, app := range snapshot.Apps {
+		app.Process = processReports[appName]
+		app.Git = gitReports[appName]
+		app.Domains = domainsReports[appName]
+		app.Checks = checksReports[appName]
+		app.Resources = resourceReports[appName]
+		app.Storage = storageReports[appName]
+		app.Network = networkReports[appName]
+		app.Nginx = nginxReports[appName]
+		app.Scheduler = schedulerReports[appName]
+		app.Certs = certsReports[appName]
+		app.DockerOptions = dockerOptionsReports[appName]
+		if registry, ok := registryReports[appName]; ok {
+			app.Registry = &registry
+		}
+
+		if app.Builder, err = c.GetAppBuilderReport(appName); err != nil {
+			return fmt.Errorf("failed to get builder report for '%s': %w", appName, err)
+		}
+		if letsEncryptInstalled {
+			if app.LetsEncrypt, err = c.GetLetsEncryptAppReport(appName); err != nil {
+				return fmt.Errorf("failed to get letsencrypt report for '%s': %w", appName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// snapshotGlobalSettings reads the --global reports, so global settings are
+// captured even on servers without apps.
+func (c *BaseClient) snapshotGlobalSettings(global *GlobalSettings, letsEncryptInstalled bool) error {
+	cmds := []string{builderReportCmd, gitReportCmd, proxyReportCmd, schedulerReportCmd, nginxReportCmd}
+	if letsEncryptInstalled {
+		cmds = append(cmds, letsEncryptAppReportCmd)
+	}
+	for _, cmd := range cmds {
+		cmd = fmt.Sprintf(cmd, "--global")
+		out, err := c.Exec(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to get global settings from '%s': %w", cmd, err)
+		}
+		if err := reports.ParseInto(out, global); err != nil {
+			return fmt.Errorf("failed to parse global settings from '%s': %w", cmd, err)
+		}
+	}
+	return nil
+}
+
+// DiffSnapshots lists the settings which differ between two snapshots, using
+// '/' separated paths into their JSON form. Runtime state such as running
+// process counts and timestamps is ignored.
+func DiffSnapshots(a, b *ServerSnapshot) ([]SnapshotChange, error) {
+	flatA, err := flattenSnapshot(a)
+	if err != nil {
+		return nil, err
+	}
+	flatB, err := flattenSnapshot(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SnapshotChange
+	for p, oldVal := range flatA {
+		newVal, ok := flatB[p]
+		if !ok {
+			changes = append(changes, SnapshotChange{Path: p, Kind: SnapshotChangeRemoved, Old: oldVal})
+		} else if oldVal != newVal {
+			changes = append(changes, SnapshotChange{Path: p, Kind: SnapshotChangeChanged, Old: oldVal, New: newVal})
+		}
+	}
+	for p, newVal := range flatB {
+		if _, ok := flatA[p]; !ok {
+			changes = append(changes, SnapshotChange{Path: p, Kind: SnapshotChangeAdded, New: newVal})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func flattenSnapshot(snapshot *ServerSnapshot) (map[string]string, error) {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+
+	flat := map[string]string{}
+	flattenValue("", generic, flat)
+	for p := range flat {
+		if isVolatileSnapshotPath(p) {
+			delete(flat, p)
+		}
+	}
+	return flat, nil
+}
+
+func flattenValue(prefix string, value interface{}, flat map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			flattenValue(path.Join(prefix, k), child, flat)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(path.Join(prefix, fmt.Sprintf("%d", i)), child, flat)
+		}
+	case nil:
+		flat[prefix] = ""
+	default:
+		flat[prefix] = fmt.Sprintf("%v", v)
+	}
+}
+
+func isVolatileSnapshotPath(p string) bool {
+	for _, pattern := range snapshotVolatilePaths {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package dokku
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotServer(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("version", "dokku version 0.34.4")
+	fe.on("config:show --global", "=====> global env vars\nCURL_TIMEOUT: 60")
+	fe.on("domains:report --global", `=====> Global domains information
+       Domains global enabled:        true
+       Domains global vhosts:         example.com`)
+	fe.on("plugin:list", "  00_dokku-standard    0.34.4 enabled    dokku core standard plugin\n  letsencrypt          0.20.0 enabled    Automated installation of let's encrypt TLS certificates")
+	fe.on("--quiet network:list", "bridge\nhost")
+	fe.on("ssh-keys:list --format json", `[{"name": "admin", "fingerprint": "SHA256:abc"}]`)
+	fe.on("apps:report", `=====> test-app app information
+       App created at:                1700000000
+       App locked:                    false`)
+	fe.on("git:report", `=====> test-app git information
+       Git deploy branch:             main
+       Git sha:                       abcdef`)
+	fe.on("nginx:report", `=====> test-app nginx information
+       Nginx global hsts:             true`)
+	fe.on("builder:report test-app", `=====> test-app builder information
+       Builder global selected:       herokuish`)
+	fe.on("letsencrypt:report test-app", `=====> test-app letsencrypt information
+       Letsencrypt global email:      ops@example.com`)
+	onGlobalSettingsReports(fe)
+
+	snapshot, err := client.SnapshotServer()
+	require.NoError(t, err)
+	assert.Equal(t, "0.34.4", snapshot.DokkuVersion)
+	assert.Equal(t, "60", snapshot.GlobalConfig["CURL_TIMEOUT"])
+	assert.Equal(t, []string{"bridge", "host"}, snapshot.Networks)
+	assert.Contains(t, snapshot.Plugins, "letsencrypt")
+	require.Contains(t, snapshot.Apps, "test-app")
+	assert.Equal(t, "main", snapshot.Apps["test-app"].Git.DeployBranch)
+	assert.Equal(t, "herokuish", snapshot.Global.Builder)
+	assert.Equal(t, "ops@example.com", snapshot.Global.LetsEncryptEmail)
+	assert.True(t, snapshot.Global.NginxHSTS)
+}
+
+func onGlobalSettingsReports(fe *fakeExecutor) {
+	fe.on("builder:report --global", `=====> global builder information
+       Builder global build dir:      app
+       Builder global selected:       herokuish`)
+	fe.on("git:report --global", `=====> global git information
+       Git global deploy branch:      main`)
+	fe.on("proxy:report --global", `=====> global proxy information
+       Proxy global type:             nginx`)
+	fe.on("scheduler:report --global", `=====> global scheduler information
+       Scheduler global selected:     docker-local`)
+	fe.on("nginx:report --global", `=====> global nginx information
+       Nginx global hsts:             true`)
+	fe.on("letsencrypt:report --global", `=====> global letsencrypt information
+       Letsencrypt global email:      ops@example.com
+       Letsencrypt global graceperiod: 2592000`)
+}
+
+func TestSnapshotServerWithoutApps(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("version", "dokku version 0.34.4")
+	fe.on("config:show --global", "=====> global env vars")
+	fe.on("domains:report --global", `=====> Global domains information
+       Domains global enabled:        true`)
+	fe.on("plugin:list", "  letsencrypt          0.20.0 enabled    Automated installation of let's encrypt TLS certificates")
+	fe.on("ssh-keys:list --format json", `[]`)
+	fe.fail("apps:report", NoDeployedAppsError)
+	onGlobalSettingsReports(fe)
+
+	snapshot, err := client.SnapshotServer()
+	require.NoError(t, err)
+	assert.Empty(t, snapshot.Apps)
+	assert.Equal(t, GlobalSettings{
+		Builder:                "herokuish",
+		BuildDir:               "app",
+		GitDeployBranch:        "main",
+		ProxyType:              "nginx",
+		Scheduler:              "docker-local",
+		NginxHSTS:              true,
+		LetsEncryptEmail:       "ops@example.com",
+		LetsEncryptGracePeriod: 2592000,
+	}, snapshot.Global)
+
+	changed := *snapshot
+	changed.Global.GitDeployBranch = "master"
+	changes, err := DiffSnapshots(snapshot, &changed)
+	require.NoError(t, err)
+	assert.Equal(t, []SnapshotChange{
+		{Path: "global/git_deploy_branch", Kind: SnapshotChangeChanged, Old: "main", New: "master"},
+	}, changes)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	baseline := &ServerSnapshot{
+		GlobalConfig: map[string]string{"A": "1", "B": "2"},
+		Networks:     []string{"bridge"},
+		Apps: map[string]*AppSnapshot{
+			"app": {
+				Git:     &GitAppReport{DeployBranch: "main", SHA: "abc"},
+				Process: &AppProcessReport{Running: true, RestartPolicy: "on-failure:10"},
+			},
+		},
+	}
+	current := &ServerSnapshot{
+		GlobalConfig: map[string]string{"A": "1", "C": "3"},
+		Networks:     []string{"bridge"},
+		Apps: map[string]*AppSnapshot{
+			"app": {
+				Git:     &GitAppReport{DeployBranch: "master", SHA: "def"},
+				Process: &AppProcessReport{Running: false, RestartPolicy: "on-failure:10"},
+			},
+		},
+	}
+
+	changes, err := DiffSnapshots(baseline, current)
+	require.NoError(t, err)
+	assert.Equal(t, []SnapshotChange{
+		{Path: "apps/app/git/deploy_branch", Kind: SnapshotChangeChanged, Old: "main", New: "master"},
+		{Path: "global_config/B", Kind: SnapshotChangeRemoved, Old: "2"},
+		{Path: "global_config/C", Kind: SnapshotChangeAdded, New: "3"},
+	}, changes)
+
+	noChanges, err := DiffSnapshots(baseline, baseline)
+	require.NoError(t, err)
+	assert.Empty(t, noChanges)
+}
+
+func TestDiffSnapshotsIgnoresContainers(t *testing.T) {
+	snapshot := func(statuses ...ProcessStatus) *ServerSnapshot {
+		return &ServerSnapshot{Apps: map[string]*AppSnapshot{
+			"app": {Process: &AppProcessReport{RestartPolicy: "on-failure:10", Statuses: statuses}},
+		}}
+	}
+	before := snapshot(ProcessStatus{Process: "web", Index: 1, Status: "running", ContainerID: "0a1b2c3d"})
+	after := snapshot(
+		ProcessStatus{Process: "web", Index: 1, Status: "running", ContainerID: "9f8e7d6c"},
+		ProcessStatus{Process: "web", Index: 2, Status: "running", ContainerID: "5a4b3c2d"},
+	)
+
+	changes, err := DiffSnapshots(before, after)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = DiffSnapshots(before, snapshot())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,203 @@
+package dokku
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type waitManager interface {
+	WaitForAppRunning(ctx context.Context, appName string, options *WaitOptions) (*AppProcessReport, error)
+	WaitForProcessCount(ctx context.Context, appName string, process string, count int, options *WaitOptions) error
+	WaitForDeploy(ctx context.Context, appName string, sha string, options *WaitOptions) (*GitAppReport, error)
+}
+
+// WaitOptions configure how often app state is polled. The interval starts at
+// InitialInterval and is multiplied by Multiplier after each poll, up to
+// MaxInterval.
+type WaitOptions struct {
+	// optional, defaults to 1 second
+	InitialInterval time.Duration
+	// optional, defaults to 15 seconds
+	MaxInterval time.Duration
+	// optional, defaults to 2
+	Multiplier float64
+}
+
+const (
+	defaultWaitInitialInterval = time.Second
+	defaultWaitMaxInterval     = 15 * time.Second
+	defaultWaitMultiplier      = 2
+)
+
+// WaitTimeoutError is returned when the context of a wait is done before the
+// app reached the expected state. It holds the last state that was observed.
+type WaitTimeoutError struct {
+	AppName    string
+	Condition  string
+	LastReport *AppProcessReport
+	LastScale  map[string]int
+	LastSHA    string
+
+	err error
+}
+
+func (e *WaitTimeoutError) Error() string {
+	state := "no state observed"
+	if e.LastReport != nil {
+		state = fmt.Sprintf("deployed=%t running=%t processes=%d",
+			e.LastReport.Deployed, e.LastReport.Running, e.LastReport.Processes)
+	}
+	if e.LastScale != nil {
+		state += fmt.Sprintf(" scale=%v", e.LastScale)
+	}
+	if e.LastSHA != "" {
+		state += fmt.Sprintf(" sha=%s", e.LastSHA)
+	}
+	return fmt.Sprintf("timed out waiting for app '%s' to %s (%s): %s", e.AppName, e.Condition, state, e.err.Error())
+}
+
+func (e *WaitTimeoutError) Unwrap() error {
+	return e.err
+}
+
+func (o *WaitOptions) intervals() func() time.Duration {
+	interval := defaultWaitInitialInterval
+	max := defaultWaitMaxInterval
+	multiplier := float64(defaultWaitMultiplier)
+	if o != nil {
+		if o.InitialInterval > 0 {
+			interval = o.InitialInterval
+		}
+		if o.MaxInterval > 0 {
+			max = o.MaxInterval
+		}
+		if o.Multiplier >= 1 {
+			multiplier = o.Multiplier
+		}
+	}
+	if interval > max {
+		interval = max
+	}
+
+	return func() time.Duration {
+		current := interval
+		interval = time.Duration(float64(interval) * multiplier)
+		if interval > max {
+			interval = max
+		}
+		return current
+	}
+}
+
+// poll calls check until it reports done, returns an error or ctx is done,
+// in which case the context error is returned.
+func poll(ctx context.Context, options *WaitOptions, check func() (bool, error)) error {
+	next := options.intervals()
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		timer := time.NewTimer(next())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func timeoutError(ctx context.Context, err error, timeoutErr *WaitTimeoutError) error {
+	if err != nil && err == ctx.Err() {
+		timeoutErr.err = err
+		return timeoutErr
+	}
+	return err
+}
+
+func (c *BaseClient) WaitForAppRunning(ctx context.Context, appName string, options *WaitOptions) (*AppProcessReport, error) {
+	timeoutErr := &WaitTimeoutError{AppName: appName, Condition: "be running"}
+	var report *AppProcessReport
+	err := poll(ctx, options, func() (bool, error) {
+		var err error
+		if report, err = c.GetAppProcessReport(appName); err != nil {
+			return false, err
+		}
+		timeoutErr.LastReport = report
+		return report.Deployed && report.Running, nil
+	})
+	if err := timeoutError(ctx, err, timeoutErr); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (c *BaseClient) WaitForProcessCount(ctx context.Context, appName string, process string, count int, options *WaitOptions) error {
+	timeoutErr := &WaitTimeoutError{
+		AppName:   appName,
+		Condition: fmt.Sprintf("run %d '%s' processes", count, process),
+	}
+	err := poll(ctx, options, func() (bool, error) {
+		scale, err := c.GetAppProcessScale(appName)
+		if err != nil {
+			return false, err
+		}
+		timeoutErr.LastScale = scale
+		if scale[process] != count {
+			return false, nil
+		}
+
+		report, err := c.GetAppProcessReport(appName)
+		if err != nil {
+			return false, err
+		}
+		timeoutErr.LastReport = report
+
+		total := 0
+		for _, n := range scale {
+			total += n
+		}
+		if report.Processes != total {
+			return false, nil
+		}
+		return count == 0 || report.Running, nil
+	})
+	return timeoutError(ctx, err, timeoutErr)
+}
+
+// WaitForDeploy waits until the app is deployed and running. If sha is not
+// empty, the deployed git revision must also match it.
+func (c *BaseClient) WaitForDeploy(ctx context.Context, appName string, sha string, options *WaitOptions) (*GitAppReport, error) {
+	timeoutErr := &WaitTimeoutError{AppName: appName, Condition: "finish deploying"}
+	if sha != "" {
+		timeoutErr.Condition = fmt.Sprintf("finish deploying %s", sha)
+	}
+	var gitReport *GitAppReport
+	err := poll(ctx, options, func() (bool, error) {
+		var err error
+		if gitReport, err = c.GitGetAppReport(appName); err != nil {
+			return false, err
+		}
+		timeoutErr.LastSHA = gitReport.SHA
+		if sha != "" && gitReport.SHA != sha {
+			return false, nil
+		}
+
+		report, err := c.GetAppProcessReport(appName)
+		if err != nil {
+			return false, err
+		}
+		timeoutErr.LastReport = report
+		return report.Deployed && report.Running, nil
+	})
+	if err := timeoutError(ctx, err, timeoutErr); err != nil {
+		return nil, err
+	}
+	return gitReport, nil
+}
@@ -0,0 +1,104 @@
+package dokku
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testWaitOptions = &WaitOptions{InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+
+func TestWaitOptionsIntervals(t *testing.T) {
+	next := (&WaitOptions{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Multiplier: 2}).intervals()
+	var intervals []time.Duration
+	for i := 0; i < 5; i++ {
+		intervals = append(intervals, next())
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, intervals)
+
+	defaults := (*WaitOptions)(nil).intervals()
+	assert.Equal(t, defaultWaitInitialInterval, defaults())
+}
+
+func TestWaitForAppRunning(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report api", "=====> api ps information\n       Deployed:   true\n       Running:    true")
+
+	report, err := client.WaitForAppRunning(context.Background(), "api", testWaitOptions)
+	require.NoError(t, err)
+	assert.True(t, report.Running)
+}
+
+func TestWaitForAppRunningMixed(t *testing.T) {
+	client, fe := newFakeClient()
+	polls := 0
+	fe.handler = func(cmd string) (string, error, bool) {
+		if cmd != "ps:report api" {
+			return "", nil, false
+		}
+		polls++
+		if polls < 3 {
+			return "=====> api ps information\n       Deployed:   true\n       Running:    mixed", nil, true
+		}
+		return "=====> api ps information\n       Deployed:   true\n       Running:    true", nil, true
+	}
+
+	report, err := client.WaitForAppRunning(context.Background(), "api", testWaitOptions)
+	require.NoError(t, err)
+	assert.True(t, report.Running)
+	assert.Equal(t, 3, polls)
+}
+
+func TestWaitForAppRunningTimeout(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:report api", "=====> api ps information\n       Deployed:   true\n       Running:    false")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.WaitForAppRunning(ctx, "api", testWaitOptions)
+
+	var timeoutErr *WaitTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, timeoutErr.LastReport.Deployed)
+	assert.False(t, timeoutErr.LastReport.Running)
+}
+
+func TestWaitForProcessCountTimeout(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("ps:scale api", "-----> Scaling for api\nproctype: qty\n--------: ---\nweb:  2\nworker: 1")
+	fe.on("ps:report api", "=====> api ps information\n       Processes:  2\n       Running:    true")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.WaitForProcessCount(ctx, "api", "web", 2, testWaitOptions)
+
+	var timeoutErr *WaitTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, map[string]int{"web": 2, "worker": 1}, timeoutErr.LastScale)
+	assert.Equal(t, 2, timeoutErr.LastReport.Processes)
+
+	fe.on("ps:report api", "=====> api ps information\n       Processes:  3\n       Running:    true")
+	assert.NoError(t, client.WaitForProcessCount(context.Background(), "api", "web", 2, testWaitOptions))
+}
+
+func TestWaitForDeploy(t *testing.T) {
+	client, fe := newFakeClient()
+	fe.on("git:report api", "=====> api git information\n       Git sha:    abc")
+	fe.on("ps:report api", "=====> api ps information\n       Deployed:   true\n       Running:    true")
+
+	report, err := client.WaitForDeploy(context.Background(), "api", "abc", testWaitOptions)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", report.SHA)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.WaitForDeploy(ctx, "api", "def", testWaitOptions)
+	var timeoutErr *WaitTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, "abc", timeoutErr.LastSHA)
+}